@@ -0,0 +1,90 @@
+// cancel.go
+package go_jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+// DefaultCancelMethod is the notification cancelling an in-flight request on a persistent connection,
+// as in the Language Server Protocol. Its params are {"id": <request id>}.
+const DefaultCancelMethod = "$/cancelRequest"
+
+// ErrRequestCancelled is the cause of a request context cancelled by the client.
+// Check it with context.Cause(ctx.Context()).
+var ErrRequestCancelled = errors.New("request cancelled")
+
+// cancelParams are the params of the cancel notification
+type cancelParams struct {
+	ID any `json:"id"`
+}
+
+// cancelMethod returns the name of the cancel notification
+func (r *JsRPC) cancelMethod() string {
+	if r == nil || r.options.CancelMethod == "" {
+		return DefaultCancelMethod
+	}
+	return r.options.CancelMethod
+}
+
+// requestKey returns the key identifying a request ID on a connection
+func requestKey(id any) string {
+	b, _ := json.Marshal(id)
+	return string(b)
+}
+
+// trackRequest makes an in-flight request cancellable by its ID.
+// The returned function must be called once the request is done.
+func (p *Peer) trackRequest(ctx context.Context, id any) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	key := requestKey(id)
+
+	p.inflightMu.Lock()
+	if p.inflight == nil {
+		p.inflight = make(map[string]context.CancelCauseFunc)
+	}
+	p.inflight[key] = cancel
+	p.inflightMu.Unlock()
+
+	return ctx, func() {
+		p.inflightMu.Lock()
+		delete(p.inflight, key)
+		p.inflightMu.Unlock()
+		cancel(nil)
+	}
+}
+
+// cancelRequest cancels the in-flight request with the given ID, reporting whether it was found
+func (p *Peer) cancelRequest(id any) bool {
+	p.inflightMu.Lock()
+	cancel, ok := p.inflight[requestKey(id)]
+	p.inflightMu.Unlock()
+	if ok {
+		cancel(ErrRequestCancelled)
+	}
+	return ok
+}
+
+// cancelRequests cancels every in-flight request, when the connection goes away
+func (p *Peer) cancelRequests(cause error) {
+	p.inflightMu.Lock()
+	defer p.inflightMu.Unlock()
+	for _, cancel := range p.inflight {
+		cancel(cause)
+	}
+}
+
+// sendCancel asks the other side to cancel an outgoing call
+func (p *Peer) sendCancel(id any) error {
+	msg, err := json.Marshal(JSONRPCRequest{JSONRPC: "2.0", Method: p.server.cancelMethod(), Params: cancelParams{ID: id}})
+	if err != nil {
+		return err
+	}
+	return p.send(msg)
+}
+
+// requestCancelled reports whether the client cancelled the request
+func (ctx *Context) requestCancelled() bool {
+	return !ctx.notification && errors.Is(context.Cause(ctx.Context()), ErrRequestCancelled)
+}
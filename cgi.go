@@ -0,0 +1,132 @@
+// cgi.go
+package go_jsonrpc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cgi"
+	"os"
+	"strings"
+)
+
+// DefaultCGIMaxBodyBytes is the request body limit of ServeCGI and ServeFastCGI when Options.CGIMaxBodyBytes is 0
+const DefaultCGIMaxBodyBytes = 10 << 20
+
+// ServeCGI serves a single request as a CGI program.
+//
+// The request is read from the CGI environment (REQUEST_METHOD, CONTENT_LENGTH, CONTENT_TYPE, HTTP_*,
+// REMOTE_ADDR, ...) and stdin, and handled with the same semantics as ServeHTTP. The response is written
+// to stdout with a Status line and headers written exactly once. Requests without a body are answered
+// with 411 Length Required and bodies over Options.CGIMaxBodyBytes with 413 Request Entity Too Large.
+// The CGI variables are available to handlers through Context.CGIVar. Stdout is reserved for the response:
+// the default logger is redirected to stderr.
+// When the request submitted asynchronous jobs, ServeCGI returns once they have finished.
+func (r *JsRPC) ServeCGI() error {
+	r.logToStderr()
+	req, err := cgi.Request()
+	if err != nil {
+		return fmt.Errorf("failed to read CGI request: %v", err)
+	}
+
+	w := newCGIResponseWriter(os.Stdout)
+	r.serveCGI(w, req, cgiEnvironment())
+
+	// The process exits once ServeCGI returns: close stdout so that the web server completes
+	// the response, then let the jobs submitted by the request finish
+	if r.jobs != nil && r.jobs.pending() {
+		_ = os.Stdout.Close()
+		_ = r.WaitJobs(context.Background())
+	}
+	return w.err
+}
+
+// serveCGI checks the request body announced by CONTENT_LENGTH before serving the request.
+// It is shared by the CGI and FastCGI transports.
+func (r *JsRPC) serveCGI(w http.ResponseWriter, req *http.Request, env map[string]string) {
+	maxBody := r.options.CGIMaxBodyBytes
+	if maxBody == 0 {
+		maxBody = DefaultCGIMaxBodyBytes
+	}
+
+	switch {
+	case req.Method == http.MethodPost && req.ContentLength <= 0:
+		http.Error(w, "request body required", http.StatusLengthRequired)
+		return
+	case req.ContentLength > maxBody:
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if req.Body == nil {
+		req.Body = http.NoBody
+	}
+
+	r.serveHTTP(w, req, env)
+}
+
+// CGIVar returns a CGI environment variable (e.g. "SCRIPT_NAME", "SERVER_SOFTWARE")
+// when the request arrived through ServeCGI or FastCGI, or "" otherwise.
+func (ctx *Context) CGIVar(name string) string {
+	if ctx.transport == nil {
+		return ""
+	}
+	return ctx.transport.cgiEnv[name]
+}
+
+// cgiEnvironment returns the process environment as a map
+func cgiEnvironment() map[string]string {
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if key, value, ok := strings.Cut(kv, "="); ok {
+			env[key] = value
+		}
+	}
+	return env
+}
+
+// cgiResponseWriter is an http.ResponseWriter producing a CGI response:
+// a Status line and the headers, written once before the body.
+type cgiResponseWriter struct {
+	w           io.Writer
+	header      http.Header
+	wroteHeader bool
+	err         error // First write error
+}
+
+func newCGIResponseWriter(w io.Writer) *cgiResponseWriter {
+	return &cgiResponseWriter{w: w, header: make(http.Header)}
+}
+
+func (c *cgiResponseWriter) Header() http.Header {
+	return c.header
+}
+
+func (c *cgiResponseWriter) WriteHeader(status int) {
+	if c.wroteHeader {
+		return
+	}
+	c.wroteHeader = true
+
+	if c.header.Get("Content-Type") == "" && status != http.StatusNoContent {
+		c.header.Set("Content-Type", "application/json")
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Status: %d %s\r\n", status, http.StatusText(status))
+	_ = c.header.Write(&sb)
+	sb.WriteString("\r\n")
+	if _, err := io.WriteString(c.w, sb.String()); err != nil && c.err == nil {
+		c.err = err
+	}
+}
+
+func (c *cgiResponseWriter) Write(p []byte) (int, error) {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+	n, err := c.w.Write(p)
+	if err != nil && c.err == nil {
+		c.err = err
+	}
+	return n, err
+}
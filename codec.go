@@ -0,0 +1,226 @@
+// codec.go
+package go_jsonrpc
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// Framer reads and writes whole JSON-RPC messages on a byte stream.
+// ReadMessage and WriteMessage may be called concurrently with each other, but not with themselves.
+type Framer interface {
+	ReadMessage() ([]byte, error) // Returns io.EOF when the stream ends between messages
+	WriteMessage(msg []byte) error
+}
+
+// Codec defines how messages are delimited on a persistent stream.
+// It is used by the server listeners, ServeStream and the jclient TCP client.
+type Codec interface {
+	NewFramer(rw io.ReadWriter) Framer
+}
+
+// DefaultMaxFrameBytes bounds the messages read by the framers of this package when
+// Options.MaxMessageBytes is not set, so a peer cannot make the reader buffer without limit
+const DefaultMaxFrameBytes = 64 << 20
+
+// frameLimit returns the effective message limit of a framer
+func frameLimit(maxBytes int64) int64 {
+	if maxBytes > 0 {
+		return maxBytes
+	}
+	return DefaultMaxFrameBytes
+}
+
+// readBody reads a message body of an announced length. The buffer grows with the bytes actually
+// received, so a length that the peer never sends costs nothing.
+func readBody(r io.Reader, length int64) ([]byte, error) {
+	var body bytes.Buffer
+	if _, err := io.CopyN(&body, r, length); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return body.Bytes(), nil
+}
+
+// NDJSONCodec frames messages as newline-delimited JSON
+type NDJSONCodec struct{}
+
+// NewFramer implements Codec
+func (NDJSONCodec) NewFramer(rw io.ReadWriter) Framer {
+	return &ndjsonFramer{reader: bufio.NewReader(rw), writer: rw}
+}
+
+type ndjsonFramer struct {
+	reader   *bufio.Reader
+	writer   io.Writer
+	maxBytes int64 // 0 means DefaultMaxFrameBytes
+}
+
+func (f *ndjsonFramer) setMaxMessageBytes(n int64) {
+	f.maxBytes = n
+}
+
+func (f *ndjsonFramer) ReadMessage() ([]byte, error) {
+	for {
+		line, err := f.readLine()
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 && (err == nil || err == io.EOF) {
+			return line, nil // A last message without trailing newline is still delivered
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readLine reads up to the next newline, failing once the line exceeds the message limit
+func (f *ndjsonFramer) readLine() ([]byte, error) {
+	maxLine := frameLimit(f.maxBytes) + 2 // Room for a trailing "\r\n"
+	var line []byte
+	for {
+		chunk, err := f.reader.ReadSlice('\n')
+		if int64(len(line)+len(chunk)) > maxLine {
+			return nil, ErrMessageTooLarge
+		}
+		line = append(line, chunk...)
+		if err != bufio.ErrBufferFull {
+			return line, err
+		}
+	}
+}
+
+func (f *ndjsonFramer) WriteMessage(msg []byte) error {
+	msg = bytes.TrimRight(msg, "\r\n")
+	if bytes.ContainsAny(msg, "\r\n") {
+		return errors.New("message contains a newline")
+	}
+	_, err := f.writer.Write(append(msg, '\n'))
+	return err
+}
+
+// ContentLengthCodec frames messages with LSP-style headers:
+// "Content-Length: N\r\n\r\n" followed by N bytes of JSON
+type ContentLengthCodec struct{}
+
+// NewFramer implements Codec
+func (ContentLengthCodec) NewFramer(rw io.ReadWriter) Framer {
+	return &contentLengthFramer{reader: textproto.NewReader(bufio.NewReader(rw)), writer: rw}
+}
+
+type contentLengthFramer struct {
+	reader   *textproto.Reader
+	writer   io.Writer
+	maxBytes int64 // 0 means DefaultMaxFrameBytes
+}
+
+func (f *contentLengthFramer) setMaxMessageBytes(n int64) {
+	f.maxBytes = n
+}
+
+func (f *contentLengthFramer) ReadMessage() ([]byte, error) {
+	header, err := f.reader.ReadMIMEHeader()
+	if err != nil {
+		if errors.Is(err, io.EOF) && len(header) == 0 {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("read message header: %w", err)
+	}
+	length, err := strconv.ParseInt(strings.TrimSpace(header.Get("Content-Length")), 10, 64)
+	if err != nil || length < 0 {
+		if errors.Is(err, strconv.ErrRange) {
+			return nil, ErrMessageTooLarge
+		}
+		return nil, fmt.Errorf("invalid Content-Length header %q", header.Get("Content-Length"))
+	}
+	if length > frameLimit(f.maxBytes) {
+		return nil, ErrMessageTooLarge
+	}
+	msg, err := readBody(f.reader.R, length)
+	if err != nil {
+		return nil, fmt.Errorf("read message body: %w", err)
+	}
+	return msg, nil
+}
+
+func (f *contentLengthFramer) WriteMessage(msg []byte) error {
+	msg = bytes.TrimRight(msg, "\r\n")
+	frame := make([]byte, 0, len(msg)+32)
+	frame = append(frame, "Content-Length: "...)
+	frame = strconv.AppendInt(frame, int64(len(msg)), 10)
+	frame = append(frame, "\r\n\r\n"...)
+	frame = append(frame, msg...)
+	_, err := f.writer.Write(frame)
+	return err
+}
+
+// NetstringCodec frames messages as netstrings: "<length>:<bytes>,"
+type NetstringCodec struct{}
+
+// NewFramer implements Codec
+func (NetstringCodec) NewFramer(rw io.ReadWriter) Framer {
+	return &netstringFramer{reader: bufio.NewReader(rw), writer: rw}
+}
+
+type netstringFramer struct {
+	reader   *bufio.Reader
+	writer   io.Writer
+	maxBytes int64 // 0 means DefaultMaxFrameBytes
+}
+
+func (f *netstringFramer) setMaxMessageBytes(n int64) {
+	f.maxBytes = n
+}
+
+// maxNetstringDigits bounds the length prefix, so a garbage stream cannot announce an absurd size
+const maxNetstringDigits = 10
+
+func (f *netstringFramer) ReadMessage() ([]byte, error) {
+	var length int64
+	for digits := 0; ; digits++ {
+		c, err := f.reader.ReadByte()
+		if err != nil {
+			if digits > 0 && errors.Is(err, io.EOF) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		if c == ':' && digits > 0 {
+			break
+		}
+		if c < '0' || c > '9' || digits == maxNetstringDigits {
+			return nil, errors.New("malformed netstring length")
+		}
+		length = length*10 + int64(c-'0')
+	}
+	if length > frameLimit(f.maxBytes) {
+		return nil, ErrMessageTooLarge
+	}
+
+	msg, err := readBody(f.reader, length+1)
+	if err != nil {
+		return nil, fmt.Errorf("read netstring: %w", err)
+	}
+	if msg[length] != ',' {
+		return nil, errors.New("netstring missing trailing comma")
+	}
+	return msg[:length], nil
+}
+
+func (f *netstringFramer) WriteMessage(msg []byte) error {
+	msg = bytes.TrimRight(msg, "\r\n")
+	frame := make([]byte, 0, len(msg)+12)
+	frame = strconv.AppendInt(frame, int64(len(msg)), 10)
+	frame = append(frame, ':')
+	frame = append(frame, msg...)
+	frame = append(frame, ',')
+	_, err := f.writer.Write(frame)
+	return err
+}
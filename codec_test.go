@@ -0,0 +1,131 @@
+package go_jsonrpc
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// pipeRW reads from a fixed input and collects what is written
+type pipeRW struct {
+	input   io.Reader
+	written bytes.Buffer
+}
+
+func (p *pipeRW) Read(b []byte) (int, error)  { return p.input.Read(b) }
+func (p *pipeRW) Write(b []byte) (int, error) { return p.written.Write(b) }
+
+func newFramer(codec Codec, input string) Framer {
+	return codec.NewFramer(&pipeRW{input: strings.NewReader(input)})
+}
+
+func TestFramerRoundTrip(t *testing.T) {
+	for name, codec := range map[string]Codec{"ndjson": NDJSONCodec{}, "content-length": ContentLengthCodec{}, "netstring": NetstringCodec{}} {
+		t.Run(name, func(t *testing.T) {
+			wire := &pipeRW{input: strings.NewReader("")}
+			writer := codec.NewFramer(wire)
+			messages := []string{`{"jsonrpc":"2.0","method":"a","id":1}`, `{}`, `[1,2,3]`}
+			for _, msg := range messages {
+				if err := writer.WriteMessage([]byte(msg)); err != nil {
+					t.Fatalf("write %s: %v", msg, err)
+				}
+			}
+
+			reader := newFramer(codec, wire.written.String())
+			for _, want := range messages {
+				got, err := reader.ReadMessage()
+				if err != nil {
+					t.Fatalf("read %s: %v", want, err)
+				}
+				if string(got) != want {
+					t.Fatalf("got %q, want %q", got, want)
+				}
+			}
+			if _, err := reader.ReadMessage(); !errors.Is(err, io.EOF) {
+				t.Fatalf("got %v at end of stream, want io.EOF", err)
+			}
+		})
+	}
+}
+
+func TestFramerBadLengths(t *testing.T) {
+	tests := []struct {
+		name     string
+		codec    Codec
+		input    string
+		maxBytes int64
+		want     error // nil means a framing error other than ErrMessageTooLarge or EOF
+	}{
+		{"content-length not a number", ContentLengthCodec{}, "Content-Length: abc\r\n\r\n{}", 0, nil},
+		{"content-length negative", ContentLengthCodec{}, "Content-Length: -1\r\n\r\n{}", 0, nil},
+		{"content-length missing", ContentLengthCodec{}, "Other: 2\r\n\r\n{}", 0, nil},
+		{"content-length max int64", ContentLengthCodec{}, "Content-Length: 9223372036854775807\r\n\r\n{}", 0, ErrMessageTooLarge},
+		{"content-length overflow", ContentLengthCodec{}, "Content-Length: 99999999999999999999\r\n\r\n{}", 0, ErrMessageTooLarge},
+		{"content-length over limit", ContentLengthCodec{}, "Content-Length: 11\r\n\r\n{}", 10, ErrMessageTooLarge},
+		{"content-length truncated", ContentLengthCodec{}, "Content-Length: 100\r\n\r\n{}", 0, io.ErrUnexpectedEOF},
+		{"netstring not a number", NetstringCodec{}, "x:{},", 0, nil},
+		{"netstring too many digits", NetstringCodec{}, "12345678901:{},", 0, nil},
+		{"netstring huge", NetstringCodec{}, "9999999999:{},", 0, ErrMessageTooLarge},
+		{"netstring over limit", NetstringCodec{}, "11:{},", 10, ErrMessageTooLarge},
+		{"netstring truncated", NetstringCodec{}, "100:{},", 0, io.ErrUnexpectedEOF},
+		{"netstring truncated length", NetstringCodec{}, "12", 0, io.ErrUnexpectedEOF},
+		{"netstring missing comma", NetstringCodec{}, "2:{};", 0, nil},
+		{"ndjson over limit", NDJSONCodec{}, `{"method":"0123456789"}` + "\n", 10, ErrMessageTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFramer(tt.codec, tt.input)
+			if tt.maxBytes > 0 {
+				f.(messageLimiter).setMaxMessageBytes(tt.maxBytes)
+			}
+			msg, err := f.ReadMessage()
+			switch {
+			case err == nil:
+				t.Fatalf("got message %q, want an error", msg)
+			case tt.want != nil && !errors.Is(err, tt.want):
+				t.Fatalf("got %v, want %v", err, tt.want)
+			case tt.want == nil && (errors.Is(err, ErrMessageTooLarge) || errors.Is(err, io.EOF)):
+				t.Fatalf("got %v, want a framing error", err)
+			}
+		})
+	}
+}
+
+func FuzzContentLengthFramer(f *testing.F) {
+	f.Add("Content-Length: 2\r\n\r\n{}")
+	f.Add("Content-Length: 9223372036854775807\r\n\r\n")
+	f.Add("Content-Length: 5\r\n\r\n{")
+	fuzzFramer(f, ContentLengthCodec{})
+}
+
+func FuzzNetstringFramer(f *testing.F) {
+	f.Add("2:{},")
+	f.Add("9999999999:")
+	f.Add("5:{,")
+	fuzzFramer(f, NetstringCodec{})
+}
+
+func FuzzNDJSONFramer(f *testing.F) {
+	f.Add("{}\n[]\n")
+	f.Add("{\"a\":1}")
+	fuzzFramer(f, NDJSONCodec{})
+}
+
+// fuzzFramer reads messages until the input fails, checking no message exceeds the input or the limit
+func fuzzFramer(f *testing.F, codec Codec) {
+	f.Fuzz(func(t *testing.T, input string) {
+		framer := newFramer(codec, input)
+		framer.(messageLimiter).setMaxMessageBytes(1 << 10)
+		for {
+			msg, err := framer.ReadMessage()
+			if err != nil {
+				return
+			}
+			if len(msg) > len(input) || len(msg) > 1<<10 {
+				t.Fatalf("message of %d bytes from %d bytes of input", len(msg), len(input))
+			}
+		}
+	})
+}
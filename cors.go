@@ -0,0 +1,179 @@
+// cors.go
+package go_jsonrpc
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSOptions configures Cross-Origin Resource Sharing for ServeHTTP
+type CORSOptions struct {
+	// AllowedOrigins lists the origins allowed to call the server. Entries are exact origins
+	// ("https://app.example.com"), "*" for any origin, or contain a single wildcard ("https://*.example.com").
+	// "*" is ignored when AllowCredentials is set: credentials require explicit origins or AllowOriginFunc.
+	AllowedOrigins []string
+	// AllowOriginFunc decides whether an origin is allowed. When set, AllowedOrigins is ignored.
+	AllowOriginFunc func(origin string) bool
+	// AllowedMethods lists the methods allowed in preflight requests. Defaults to the methods ServeHTTP accepts.
+	AllowedMethods []string
+	// AllowedHeaders lists the request headers allowed in preflight requests, "*" allows any.
+	// Defaults to Content-Type and the trace context headers.
+	AllowedHeaders []string
+	// ExposedHeaders lists the response headers readable by the browser
+	ExposedHeaders []string
+	// AllowCredentials lets browsers send cookies and HTTP auth. The request origin is then echoed instead of "*",
+	// so only origins matched by an explicit AllowedOrigins entry or by AllowOriginFunc are allowed.
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response. 0 omits the header.
+	MaxAge time.Duration
+}
+
+// handleCORS applies the CORS policy to the response. It returns true when the request
+// was a preflight request and has been fully answered.
+func (r *JsRPC) handleCORS(w http.ResponseWriter, req *http.Request) bool {
+	cors := r.options.CORS
+	origin := req.Header.Get("Origin")
+	preflight := req.Method == http.MethodOptions && req.Header.Get("Access-Control-Request-Method") != ""
+
+	header := w.Header()
+	if preflight {
+		header.Add("Vary", "Origin")
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+	} else if !cors.allowsAnyOrigin() || cors.AllowCredentials {
+		// The response depends on the Origin header whenever the origin is echoed back
+		header.Add("Vary", "Origin")
+	}
+
+	if origin == "" || !cors.allowsOrigin(origin) {
+		if preflight {
+			w.WriteHeader(http.StatusNoContent)
+		}
+		return preflight
+	}
+
+	if preflight {
+		method := req.Header.Get("Access-Control-Request-Method")
+		requested := parseHeaderList(req.Header.Get("Access-Control-Request-Headers"))
+		if !containsFold(cors.allowedMethods(r.options.HTTPAllowGet), method) || !cors.allowsHeaders(requested) {
+			w.WriteHeader(http.StatusNoContent)
+			return true
+		}
+		cors.setAllowOrigin(header, origin)
+		header.Set("Access-Control-Allow-Methods", strings.Join(cors.allowedMethods(r.options.HTTPAllowGet), ", "))
+		if len(requested) > 0 {
+			header.Set("Access-Control-Allow-Headers", strings.Join(requested, ", "))
+		}
+		if cors.MaxAge > 0 {
+			header.Set("Access-Control-Max-Age", strconv.Itoa(int(cors.MaxAge.Seconds())))
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return true
+	}
+
+	cors.setAllowOrigin(header, origin)
+	if len(cors.ExposedHeaders) > 0 {
+		header.Set("Access-Control-Expose-Headers", strings.Join(cors.ExposedHeaders, ", "))
+	}
+	return false
+}
+
+// wildcardWithCredentials reports whether "*" is allowed along with credentials, a combination that is ignored
+func (c *CORSOptions) wildcardWithCredentials() bool {
+	return c != nil && c.AllowCredentials && c.AllowOriginFunc == nil && containsFold(c.AllowedOrigins, "*")
+}
+
+func (c *CORSOptions) setAllowOrigin(header http.Header, origin string) {
+	if c.allowsAnyOrigin() {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+	if c.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (c *CORSOptions) allowsAnyOrigin() bool {
+	if c.AllowOriginFunc != nil || c.AllowCredentials {
+		return false
+	}
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CORSOptions) allowsOrigin(origin string) bool {
+	if c.AllowOriginFunc != nil {
+		return c.AllowOriginFunc(origin)
+	}
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" {
+			if c.AllowCredentials {
+				continue // Echoing any origin with credentials would let every site act as the user
+			}
+			return true
+		}
+		if strings.EqualFold(allowed, origin) {
+			return true
+		}
+		if prefix, suffix, ok := strings.Cut(strings.ToLower(allowed), "*"); ok {
+			lower := strings.ToLower(origin)
+			if len(lower) >= len(prefix)+len(suffix) && strings.HasPrefix(lower, prefix) && strings.HasSuffix(lower, suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *CORSOptions) allowedMethods(allowGet bool) []string {
+	if len(c.AllowedMethods) > 0 {
+		return c.AllowedMethods
+	}
+	if allowGet {
+		return []string{http.MethodGet, http.MethodPost}
+	}
+	return []string{http.MethodPost}
+}
+
+func (c *CORSOptions) allowsHeaders(requested []string) bool {
+	allowed := c.AllowedHeaders
+	if len(allowed) == 0 {
+		allowed = []string{"Content-Type", TraceparentHeader, TracestateHeader}
+	}
+	if containsFold(allowed, "*") {
+		return true
+	}
+	for _, h := range requested {
+		if !containsFold(allowed, h) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseHeaderList splits a comma-separated header value
+func parseHeaderList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
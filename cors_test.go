@@ -0,0 +1,83 @@
+package go_jsonrpc
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// quietOptions returns options whose logs are discarded
+func quietOptions() *Options {
+	return &Options{SlogLogger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+}
+
+func preflight(r *JsRPC, origin string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodOptions, "/", nil)
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	req.Header.Set("Access-Control-Request-Headers", "Content-Type")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCORSPreflight(t *testing.T) {
+	tests := []struct {
+		name            string
+		cors            CORSOptions
+		origin          string
+		wantOrigin      string
+		wantCredentials bool
+	}{
+		{"any origin", CORSOptions{AllowedOrigins: []string{"*"}}, "https://a.example", "*", false},
+		{"exact origin", CORSOptions{AllowedOrigins: []string{"https://a.example"}}, "https://a.example", "https://a.example", false},
+		{"other origin", CORSOptions{AllowedOrigins: []string{"https://a.example"}}, "https://b.example", "", false},
+		{"subdomain pattern", CORSOptions{AllowedOrigins: []string{"https://*.example.com"}}, "https://app.example.com", "https://app.example.com", false},
+		{"pattern mismatch", CORSOptions{AllowedOrigins: []string{"https://*.example.com"}}, "https://example.com.evil", "", false},
+		{"credentials with origin", CORSOptions{AllowedOrigins: []string{"https://a.example"}, AllowCredentials: true}, "https://a.example", "https://a.example", true},
+		{"credentials with any origin", CORSOptions{AllowedOrigins: []string{"*"}, AllowCredentials: true}, "https://evil.example", "", false},
+		{"credentials with any origin and list", CORSOptions{AllowedOrigins: []string{"*", "https://a.example"}, AllowCredentials: true}, "https://a.example", "https://a.example", true},
+		{"credentials with func", CORSOptions{AllowOriginFunc: func(o string) bool { return o == "https://a.example" }, AllowCredentials: true}, "https://a.example", "https://a.example", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := quietOptions()
+			options.CORS = &tt.cors
+			rec := preflight(New(options), tt.origin)
+			if rec.Code != http.StatusNoContent {
+				t.Fatalf("got status %d, want 204", rec.Code)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Fatalf("got Access-Control-Allow-Origin %q, want %q", got, tt.wantOrigin)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Credentials") == "true"; got != tt.wantCredentials {
+				t.Fatalf("got credentials %v, want %v", got, tt.wantCredentials)
+			}
+		})
+	}
+}
+
+func TestCORSWebSocketOrigin(t *testing.T) {
+	options := quietOptions()
+	options.CORS = &CORSOptions{AllowedOrigins: []string{"*"}, AllowCredentials: true}
+	r := New(options)
+	req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	if r.checkWebSocketOrigin(req, &WebSocketOptions{}) {
+		t.Fatal("credentialed WebSocket allowed from any origin")
+	}
+}
+
+func TestCORSWildcardWithCredentialsWarning(t *testing.T) {
+	var logs strings.Builder
+	New(&Options{
+		SlogLogger: slog.New(slog.NewTextHandler(&logs, nil)),
+		CORS:       &CORSOptions{AllowedOrigins: []string{"*"}, AllowCredentials: true},
+	})
+	if !strings.Contains(logs.String(), "AllowCredentials") {
+		t.Fatalf("no warning logged: %q", logs.String())
+	}
+}
@@ -1,20 +1,43 @@
 package go_jsonrpc
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"io"
+	"log/slog"
+	"net/http"
 )
 
 type Context struct {
-	Method   string         // The method being executed
-	Params   any            // Params can be either an array or a map
-	ID       interface{}    // The ID of the JSON-RPC request
-	Response interface{}    // The response to be sent
-	writer   io.Writer      // Writer for the response
-	data     map[string]any // To store shared data between middleware and handlers
-	Logger   Logger         // Logger available for handlers and middlewares
-	cgi      bool           // Flag to control CGI header output
+	Method         string          // The method being executed
+	Params         any             // Params can be either an array or a map
+	ID             interface{}     // The ID of the JSON-RPC request
+	Response       interface{}     // The response to be sent
+	RemoteAddr     string          // Remote address of the client, when the transport provides one
+	writer         io.Writer       // Writer for the response
+	meta           map[string]any  // Out-of-band request metadata
+	data           map[string]any  // To store shared data between middleware and handlers
+	session        *Session        // Request-scoped session on single-request transports
+	Logger         Logger          // Logger available for handlers and middlewares
+	Slog           *slog.Logger    // Structured logger carrying the request attributes (method, id, remote)
+	server         *JsRPC          // Server dispatching the request
+	stdctx         context.Context // Request context carrying the current span
+	transport      *transport      // Transport the request arrived on
+	notification   bool            // The request has no ID
+	responseHeader http.Header     // Response headers for HTTP transports
+	responseStatus int             // Response status override for HTTP transports
+	responded      bool            // Set once a response has been written
+	errorCode      int             // JSON-RPC error code of the response, 0 on success
+}
+
+// Context returns the context.Context of the request.
+// It carries the current tracing span, so handlers can start their own child spans from it.
+func (ctx *Context) Context() context.Context {
+	if ctx.stdctx == nil {
+		return context.Background()
+	}
+	return ctx.stdctx
 }
 
 // JSON writes a JSON-RPC 2.0 response with the provided result
@@ -24,15 +47,9 @@ func (ctx *Context) JSON(result interface{}) error {
 		Result:  result,
 		ID:      ctx.ID,
 	}
+	ctx.Response = result
 
-	// Write CGI headers if running in CGI mode
-	if ctx.cgi {
-		if _, err := ctx.writer.Write([]byte("Content-Type: application/json\r\n\r\n")); err != nil {
-			return err
-		}
-	}
-
-	return json.NewEncoder(ctx.writer).Encode(response)
+	return ctx.writeResponse(response)
 }
 
 // Error writes a JSON-RPC 2.0 error response with a custom error code and error object
@@ -46,14 +63,7 @@ func (ctx *Context) Error(code int, err error) error {
 		ID: ctx.ID,
 	}
 
-	// Write CGI headers if running in CGI mode
-	if ctx.cgi {
-		if _, err := ctx.writer.Write([]byte("Content-Type: application/json\r\n\r\n")); err != nil {
-			return err
-		}
-	}
-
-	return json.NewEncoder(ctx.writer).Encode(response)
+	return ctx.writeResponse(response)
 }
 
 // ErrorString writes a JSON-RPC 2.0 error response with a custom error code and a simple error message
@@ -67,16 +77,35 @@ func (ctx *Context) ErrorString(code int, message string) error {
 		ID: ctx.ID,
 	}
 
-	// Write CGI headers if running in CGI mode
-	if ctx.cgi {
-		if _, err := ctx.writer.Write([]byte("Content-Type: application/json\r\n\r\n")); err != nil {
-			return err
-		}
+	return ctx.writeResponse(response)
+}
+
+// writeResponse encodes the response to the writer, recording its outcome for logging
+func (ctx *Context) writeResponse(response JSONRPCResponse) error {
+	ctx.responded = true
+	if ctx.requestCancelled() {
+		response.Result = nil
+		response.Error = &JSONRPCError{Code: RequestCancelled, Message: "request cancelled"}
+	}
+	if response.Error != nil {
+		ctx.errorCode = response.Error.Code
+	}
+	if ctx.transport != nil && ctx.transport.beforeResponse != nil {
+		ctx.transport.beforeResponse(ctx, &response)
 	}
 
 	return json.NewEncoder(ctx.writer).Encode(response)
 }
 
+// RedactedParams returns a copy of the params with the configured redaction rules applied.
+// Use it when recording params in custom logs or audit trails.
+func (ctx *Context) RedactedParams() any {
+	if ctx.server == nil {
+		return ctx.Params
+	}
+	return ctx.server.Redact(ctx.Method, ctx.Params)
+}
+
 // Bind binds the params to the provided destination struct
 func (ctx *Context) Bind(dest interface{}) error {
 	bytes, err := json.Marshal(ctx.Params)
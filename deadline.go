@@ -0,0 +1,96 @@
+// deadline.go
+package go_jsonrpc
+
+import (
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// deadlineConn applies the Options timeouts to a connection accepted by a listener.
+// The connection is read by a single goroutine, which calls awaitMessage before reading each message.
+// While requests, outgoing calls or subscriptions are active, the connection is not idle: no deadline
+// applies to the next message until the last of them ends.
+type deadlineConn struct {
+	net.Conn
+	readTimeout  time.Duration // Time to read a message once its first byte arrived
+	writeTimeout time.Duration // Time to complete each write
+
+	mu           sync.Mutex
+	awaiting     bool          // Waiting for the first byte of a message
+	awaitTimeout time.Duration // Time to wait for that first byte once the connection is idle
+	active       int           // Requests, outgoing calls and subscriptions in progress
+}
+
+// newDeadlineConn wraps conn when timeouts are configured, otherwise it returns nil
+func (r *JsRPC) newDeadlineConn(conn net.Conn) *deadlineConn {
+	o := r.options
+	if o.FirstByteTimeout <= 0 && o.ReadTimeout <= 0 && o.WriteTimeout <= 0 && o.IdleTimeout <= 0 {
+		return nil
+	}
+	return &deadlineConn{Conn: conn, readTimeout: o.ReadTimeout, writeTimeout: o.WriteTimeout}
+}
+
+// awaitMessage sets the deadline for the first byte of the next message
+func (c *deadlineConn) awaitMessage(timeout time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.awaiting = true
+	c.awaitTimeout = timeout
+	c.armIdleDeadline()
+}
+
+// activity counts a request, outgoing call or subscription starting (1) or ending (-1)
+func (c *deadlineConn) activity(delta int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.active += delta
+	if c.awaiting && (c.active == 0 || c.active == delta) {
+		c.armIdleDeadline() // The connection became idle, or stopped being idle
+	}
+}
+
+// armIdleDeadline sets the deadline of a connection waiting for a message. c.mu must be held.
+func (c *deadlineConn) armIdleDeadline() {
+	if c.active > 0 {
+		_ = c.Conn.SetReadDeadline(time.Time{})
+		return
+	}
+	_ = c.Conn.SetReadDeadline(deadlineAfter(c.awaitTimeout))
+}
+
+func (c *deadlineConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.mu.Lock()
+		if c.awaiting {
+			// The message started: the rest of it must arrive within the read timeout
+			c.awaiting = false
+			_ = c.Conn.SetReadDeadline(deadlineAfter(c.readTimeout))
+		}
+		c.mu.Unlock()
+	}
+	return n, err
+}
+
+func (c *deadlineConn) Write(p []byte) (int, error) {
+	if c.writeTimeout > 0 {
+		_ = c.Conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	}
+	return c.Conn.Write(p)
+}
+
+// deadlineAfter returns the deadline for a timeout, or no deadline for 0
+func deadlineAfter(timeout time.Duration) time.Time {
+	if timeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(timeout)
+}
+
+// isTimeout reports whether err is a deadline expiration
+func isTimeout(err error) bool {
+	return errors.Is(err, os.ErrDeadlineExceeded)
+}
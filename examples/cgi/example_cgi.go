@@ -17,14 +17,16 @@ type UserInfo struct {
 }
 
 func main() {
-	// Create an instance of JsRPC with the CGI flag set to true to write CGI headers
-	jsrpc := go_jsonrpc.New(&go_jsonrpc.Options{CGI: true})
+	// Create an instance of JsRPC. Stdout carries the CGI response, so a custom logger must write to stderr
+	jsrpc := go_jsonrpc.New(&go_jsonrpc.Options{
+		Logger: log.New(os.Stderr, "JSON-RPC CGI: ", log.LstdFlags),
+	})
 
 	// Global middleware to add a request ID to the context
 	jsrpc.UseGlobalMiddleware(func(ctx *go_jsonrpc.Context) error {
 		// Store a unique request ID in the context
 		ctx.SetData("request_id", 1001)
-		fmt.Println("Global middleware executed")
+		ctx.Slog.Debug("global middleware executed", "script", ctx.CGIVar("SCRIPT_NAME"))
 		return nil
 	})
 
@@ -61,8 +63,8 @@ func main() {
 		})
 	})
 
-	// Execute the command using os.Stdin and os.Stdout as input and output (for CGI)
-	if err := jsrpc.ExecuteCommand(os.Stdin, os.Stdout); err != nil {
+	// Serve the request described by the CGI environment, reading stdin and writing stdout
+	if err := jsrpc.ServeCGI(); err != nil {
 		log.Printf("Error: %v\n", err)
 	}
 }
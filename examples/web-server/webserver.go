@@ -4,6 +4,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/pablolagos/go-jsonrpc"
 )
@@ -12,6 +13,10 @@ func main() {
 	options := &go_jsonrpc.Options{
 		CGI:    false,
 		Logger: log.New(os.Stdout, "JSON-RPC HTTP Server: ", log.LstdFlags),
+		CORS: &go_jsonrpc.CORSOptions{
+			AllowedOrigins: []string{"*"},
+			MaxAge:         10 * time.Minute,
+		},
 	}
 
 	jsrpc := go_jsonrpc.New(options)
@@ -27,22 +32,9 @@ func main() {
 		return nil
 	})
 
-	http.HandleFunc("/rpc", func(w http.ResponseWriter, r *http.Request) {
-		// Handle preflight OPTIONS request for CORS (optional)
-		if r.Method == http.MethodOptions {
-			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-			w.WriteHeader(http.StatusNoContent) // No content for preflight response
-			return
-		}
-
-		// Add CORS headers to the actual response (optional)
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Content-Type", "application/json") // Set your preferred content type
-
-		jsrpc.ExecuteCommand(r.Body, w)
-	})
+	// JsRPC implements http.Handler: it checks the method and Content-Type,
+	// maps errors to status codes, joins the caller's trace and answers CORS preflight requests
+	http.Handle("/rpc", jsrpc)
 
 	log.Println("Starting HTTP server on :8080")
 	if err := http.ListenAndServe(":8080", nil); err != nil {
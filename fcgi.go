@@ -0,0 +1,34 @@
+// fcgi.go
+package go_jsonrpc
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/fcgi"
+)
+
+// ServeFastCGI serves FastCGI requests accepted on listener from a long-lived process.
+// Requests are handled with the same semantics as ServeCGI, and the CGI variables passed by the
+// web server are available through Context.CGIVar. If listener is nil, requests are accepted on
+// stdin, as when the process is spawned by the web server.
+// This function blocks execution.
+func (r *JsRPC) ServeFastCGI(listener net.Listener) error {
+	err := fcgi.Serve(listener, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.serveCGI(w, req, fcgi.ProcessEnv(req))
+	}))
+	if errors.Is(err, net.ErrClosed) {
+		return nil // Listener closed, exit gracefully
+	}
+	return err
+}
+
+// StartFastCGI listens on a TCP port or Unix socket and serves FastCGI requests.
+// This function blocks execution.
+func (r *JsRPC) StartFastCGI(address string, useUnixSocket bool) error {
+	listener, err := r.listen(address, useUnixSocket)
+	if err != nil {
+		return err
+	}
+	return r.ServeFastCGI(listener)
+}
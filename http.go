@@ -0,0 +1,257 @@
+// http.go
+package go_jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"mime"
+	"net/http"
+)
+
+// ServeHTTP implements http.Handler, so a JsRPC can be mounted directly on an http.ServeMux.
+//
+// Requests are accepted with POST and a JSON Content-Type (415 otherwise), or with GET when
+// Options.HTTPAllowGet is set, using the "method", "params" (JSON-encoded) and "id" query values.
+// Responses are sent as application/json with a status code derived from the JSON-RPC error code,
+// and notifications are answered with 204 No Content. CORS is applied when Options.CORS is set.
+// Handlers can reach the *http.Request through Context.HTTPRequest.
+func (r *JsRPC) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.serveHTTP(w, req, nil)
+}
+
+// serveHTTP serves an HTTP request; cgiEnv holds the CGI variables when called from a CGI transport
+func (r *JsRPC) serveHTTP(w http.ResponseWriter, req *http.Request, cgiEnv map[string]string) {
+	if r.options.CORS != nil && r.handleCORS(w, req) {
+		return // Preflight request answered
+	}
+
+	var body *bytes.Reader
+	switch req.Method {
+	case http.MethodPost:
+		if !isJSONContentType(req.Header.Get("Content-Type")) {
+			http.Error(w, "unsupported content type, expected application/json", http.StatusUnsupportedMediaType)
+			return
+		}
+	case http.MethodGet:
+		if !r.options.HTTPAllowGet {
+			r.methodNotAllowed(w)
+			return
+		}
+		var ok bool
+		if body, ok = requestFromQuery(req); !ok {
+			http.Error(w, "invalid query, expected method, params and id values", http.StatusBadRequest)
+			return
+		}
+	default:
+		r.methodNotAllowed(w)
+		return
+	}
+
+	res := &httpResponse{w: w}
+	t := &transport{
+		ctx:            ExtractTraceHeaders(req.Context(), req.Header),
+		remoteAddr:     req.RemoteAddr,
+		httpRequest:    req,
+		cgiEnv:         cgiEnv,
+		beforeResponse: res.beforeResponse,
+		afterDispatch:  res.afterDispatch,
+	}
+	var err error
+	if body != nil {
+		err = r.executeCommandWithData(body, res, nil, t)
+	} else {
+		err = r.executeCommandWithData(req.Body, res, nil, t)
+	}
+	if err != nil {
+		r.slog.Error("error processing request", slog.String("remote", req.RemoteAddr), slog.Any("error", err))
+		if !res.wroteHeader {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	res.finish()
+}
+
+func (r *JsRPC) methodNotAllowed(w http.ResponseWriter) {
+	if r.options.HTTPAllowGet {
+		w.Header().Set("Allow", "GET, POST")
+	} else {
+		w.Header().Set("Allow", "POST")
+	}
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+}
+
+// HTTPRequest returns the HTTP request the call arrived on, or nil for other transports.
+// It gives handlers access to the headers, remote address and TLS state.
+func (ctx *Context) HTTPRequest() *http.Request {
+	if ctx.transport == nil {
+		return nil
+	}
+	return ctx.transport.httpRequest
+}
+
+// ResponseHeader returns the header map sent with the response on HTTP transports.
+// Other transports ignore it. Changes must be made before the response is written.
+func (ctx *Context) ResponseHeader() http.Header {
+	if ctx.responseHeader == nil {
+		ctx.responseHeader = make(http.Header)
+	}
+	return ctx.responseHeader
+}
+
+// SetCookie adds a Set-Cookie header to the response on HTTP transports.
+// Invalid cookies are silently dropped, as with http.SetCookie.
+func (ctx *Context) SetCookie(cookie *http.Cookie) {
+	if v := cookie.String(); v != "" {
+		ctx.ResponseHeader().Add("Set-Cookie", v)
+	}
+}
+
+// SetStatus overrides the HTTP status of the response on HTTP transports.
+// By default the status is derived from the JSON-RPC error code.
+func (ctx *Context) SetStatus(status int) {
+	ctx.responseStatus = status
+}
+
+// HTTPStatusForCode returns the HTTP status used for a JSON-RPC error code
+// when Options.HTTPStatusFunc is not set
+func HTTPStatusForCode(code int) int {
+	switch code {
+	case 0:
+		return http.StatusOK
+	case ParseError, InvalidRequest, InvalidParams:
+		return http.StatusBadRequest
+	case MethodNotFound:
+		return http.StatusNotFound
+	case InternalError:
+		return http.StatusInternalServerError
+	case RequestTooLarge:
+		return http.StatusRequestEntityTooLarge
+	default:
+		return http.StatusOK // Application-defined errors are delivered as regular responses
+	}
+}
+
+// httpResponse is the response writer handed to the dispatcher for HTTP requests
+type httpResponse struct {
+	w           http.ResponseWriter
+	wroteHeader bool
+	discard     bool // Drop the body, used for notifications
+}
+
+// finish answers 204 No Content when nothing was written: the request was a notification,
+// or a HandlerInterceptor finished it without a response
+func (h *httpResponse) finish() {
+	if !h.wroteHeader {
+		h.writeHeader(http.StatusNoContent)
+	}
+}
+
+func (h *httpResponse) writeHeader(status int) {
+	h.wroteHeader = true
+	h.w.WriteHeader(status)
+}
+
+// beforeResponse sets the status code and headers right before the JSON-RPC response is encoded
+func (h *httpResponse) beforeResponse(ctx *Context, response *JSONRPCResponse) {
+	if h.wroteHeader {
+		return
+	}
+	h.applyMeta(ctx)
+	if ctx.notification {
+		// Notifications get no response body
+		h.discard = true
+		h.writeHeader(http.StatusNoContent)
+		return
+	}
+
+	code := 0
+	if response.Error != nil {
+		code = response.Error.Code
+	}
+	status := HTTPStatusForCode(code)
+	if ctx.server.options.HTTPStatusFunc != nil {
+		status = ctx.server.options.HTTPStatusFunc(code)
+	}
+	if ctx.responseStatus != 0 {
+		status = ctx.responseStatus
+	}
+	h.w.Header().Set("Content-Type", "application/json")
+	h.writeHeader(status)
+}
+
+// afterDispatch applies the response metadata when the handler sent no response
+func (h *httpResponse) afterDispatch(ctx *Context) {
+	if !h.wroteHeader {
+		h.applyMeta(ctx)
+	}
+}
+
+// applyMeta copies the headers set by the handler into the response
+func (h *httpResponse) applyMeta(ctx *Context) {
+	for key, values := range ctx.responseHeader {
+		h.w.Header()[key] = append(h.w.Header()[key], values...)
+	}
+}
+
+func (h *httpResponse) Write(p []byte) (int, error) {
+	if h.discard {
+		return len(p), nil
+	}
+	if !h.wroteHeader {
+		// Written outside a JSON-RPC response, e.g. by a handler interceptor
+		h.w.Header().Set("Content-Type", "application/json")
+		h.writeHeader(http.StatusOK)
+	}
+	return h.w.Write(p)
+}
+
+// Flush sends the buffered body to the client, for streamed responses
+func (h *httpResponse) Flush() {
+	if f, ok := h.w.(http.Flusher); ok && !h.discard {
+		f.Flush()
+	}
+}
+
+// isJSONContentType reports whether a Content-Type header denotes a JSON body
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch mediaType {
+	case "application/json", "application/json-rpc", "application/jsonrequest":
+		return true
+	}
+	return false
+}
+
+// requestFromQuery builds a JSON-RPC request body from GET query values
+func requestFromQuery(req *http.Request) (*bytes.Reader, bool) {
+	query := req.URL.Query()
+	rpcRequest := JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  query.Get("method"),
+	}
+	if rpcRequest.Method == "" {
+		return nil, false
+	}
+	if params := query.Get("params"); params != "" {
+		if err := json.Unmarshal([]byte(params), &rpcRequest.Params); err != nil {
+			return nil, false
+		}
+	}
+	if id := query.Get("id"); id != "" {
+		// Numeric IDs are kept as numbers, anything else is used as a string ID
+		if err := json.Unmarshal([]byte(id), &rpcRequest.ID); err != nil {
+			rpcRequest.ID = id
+		}
+	}
+	data, err := json.Marshal(rpcRequest)
+	if err != nil {
+		return nil, false
+	}
+	return bytes.NewReader(data), true
+}
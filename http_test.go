@@ -0,0 +1,82 @@
+package go_jsonrpc
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTTPStatus(t *testing.T) {
+	r := New(quietOptions())
+	r.RegisterCommand("echo", func(ctx *Context) error {
+		return ctx.JSON(ctx.Params)
+	})
+	r.RegisterCommand("invalid", func(ctx *Context) error {
+		return ctx.ErrorString(InvalidParams, "invalid params")
+	})
+	r.RegisterCommand("app-error", func(ctx *Context) error {
+		return ctx.ErrorString(1000, "application error")
+	})
+	r.RegisterCommand("fail", func(ctx *Context) error {
+		return errors.New("failed")
+	})
+	r.RegisterCommand("silent", func(ctx *Context) error {
+		return nil
+	})
+	r.RegisterCommand("rejected", func(ctx *Context) error {
+		return ctx.JSON("unreachable")
+	}, func(ctx *Context) error {
+		return errors.New("rejected")
+	})
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantCode   int // 0 for a result or no body
+	}{
+		{"result", `{"jsonrpc":"2.0","method":"echo","params":[1],"id":1}`, http.StatusOK, 0},
+		{"method not found", `{"jsonrpc":"2.0","method":"missing","id":1}`, http.StatusNotFound, MethodNotFound},
+		{"invalid params", `{"jsonrpc":"2.0","method":"invalid","id":1}`, http.StatusBadRequest, InvalidParams},
+		{"application error", `{"jsonrpc":"2.0","method":"app-error","id":1}`, http.StatusOK, 1000},
+		{"handler error", `{"jsonrpc":"2.0","method":"fail","id":1}`, http.StatusInternalServerError, InternalError},
+		{"no response", `{"jsonrpc":"2.0","method":"silent","id":1}`, http.StatusInternalServerError, InternalError},
+		{"middleware error", `{"jsonrpc":"2.0","method":"rejected","id":1}`, http.StatusInternalServerError, InternalError},
+		{"parse error", `{`, http.StatusBadRequest, ParseError},
+		{"notification", `{"jsonrpc":"2.0","method":"echo","params":[1]}`, http.StatusNoContent, 0},
+		{"failed notification", `{"jsonrpc":"2.0","method":"fail"}`, http.StatusNoContent, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, res := postJSON(t, r, tt.body)
+			if status != tt.wantStatus {
+				t.Fatalf("got status %d, want %d", status, tt.wantStatus)
+			}
+			switch {
+			case tt.wantStatus == http.StatusNoContent:
+				if res != nil {
+					t.Fatalf("got %+v, want no body", res)
+				}
+			case res == nil:
+				t.Fatal("no response")
+			case tt.wantCode == 0 && res.Error != nil:
+				t.Fatalf("got error %v, want a result", res.Error)
+			case tt.wantCode != 0 && (res.Error == nil || res.Error.Code != tt.wantCode):
+				t.Fatalf("got %+v, want error code %d", res, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestHTTPContentType(t *testing.T) {
+	r := New(quietOptions())
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"jsonrpc":"2.0","method":"x","id":1}`))
+	req.Header.Set("Content-Type", "text/plain")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("got status %d, want 415", rec.Code)
+	}
+}
@@ -2,19 +2,24 @@ package jclient
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"time"
+
+	"github.com/pablolagos/go-jsonrpc"
 )
 
 // Request represents a JSON-RPC request
 type Request struct {
-	JSONRPC string      `json:"jsonrpc"`
-	Method  string      `json:"method"`
-	Params  interface{} `json:"params,omitempty"`
-	ID      int         `json:"id"`
+	JSONRPC string         `json:"jsonrpc"`
+	Method  string         `json:"method"`
+	Params  interface{}    `json:"params,omitempty"`
+	ID      int            `json:"id"`
+	Meta    map[string]any `json:"meta,omitempty"` // Out-of-band metadata such as the trace context
 }
 
 // Response represents a JSON-RPC response
@@ -35,11 +40,15 @@ type RPCError struct {
 type HTTPClient struct {
 	endpoint string
 	client   *http.Client
+	tracer   go_jsonrpc.Tracer
+	logger   *slog.Logger
 }
 
 type HTTPClientOptions struct {
-	Insecure bool          // Allow self-signed certificates
-	Timeout  time.Duration // HTTP client timeout
+	Insecure bool              // Allow self-signed certificates
+	Timeout  time.Duration     // HTTP client timeout
+	Tracer   go_jsonrpc.Tracer // Optional tracer starting a client span per call
+	Logger   *slog.Logger      // Optional logger for calls, including the trace ID
 }
 
 // NewHTTPClient creates a new JSON-RPC client with HTTPS support
@@ -53,11 +62,22 @@ func NewHTTPClient(endpoint string, opts HTTPClientOptions) *HTTPClient {
 			Transport: tr,
 			Timeout:   opts.Timeout,
 		},
+		tracer: opts.Tracer,
+		logger: opts.Logger,
 	}
 }
 
 // Call performs a JSON-RPC call and decodes into result
 func (c *HTTPClient) Call(method string, params interface{}, result interface{}) error {
+	return c.CallContext(context.Background(), method, params, result)
+}
+
+// CallContext performs a JSON-RPC call bound to ctx and decodes into result.
+// The trace context found in ctx (or a new one) is sent in the traceparent and tracestate headers.
+func (c *HTTPClient) CallContext(ctx context.Context, method string, params interface{}, result interface{}) (err error) {
+	ctx, finish := startCall(ctx, c.tracer, c.logger, method)
+	defer func() { finish(err) }()
+
 	req := Request{
 		JSONRPC: "2.0",
 		Method:  method,
@@ -70,11 +90,12 @@ func (c *HTTPClient) Call(method string, params interface{}, result interface{})
 		return fmt.Errorf("marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequest("POST", c.endpoint, bytes.NewReader(data))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.endpoint, bytes.NewReader(data))
 	if err != nil {
 		return fmt.Errorf("create request: %w", err)
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
+	go_jsonrpc.InjectTraceHeaders(ctx, httpReq.Header)
 
 	resp, err := c.client.Do(httpReq)
 	if err != nil {
@@ -87,15 +108,5 @@ func (c *HTTPClient) Call(method string, params interface{}, result interface{})
 		return fmt.Errorf("decode response: %w", err)
 	}
 
-	if rpcResp.Error != nil {
-		return fmt.Errorf("rpc error %d: %s", rpcResp.Error.Code, rpcResp.Error.Message)
-	}
-
-	if rpcResp.Result != nil && result != nil {
-		if err := json.Unmarshal(*rpcResp.Result, result); err != nil {
-			return fmt.Errorf("unmarshal result: %w", err)
-		}
-	}
-
-	return nil
+	return decodeResult(&rpcResp, result)
 }
@@ -0,0 +1,28 @@
+package jclient
+
+import (
+	"context"
+
+	"github.com/pablolagos/go-jsonrpc"
+)
+
+type progressTokenKey struct{}
+
+// WithProgressToken returns a copy of ctx that makes calls request progress updates with token.
+// On a persistent connection the updates arrive through TCPClientOpts.OnNotification
+// as "$/progress" notifications carrying the token.
+func WithProgressToken(ctx context.Context, token any) context.Context {
+	return context.WithValue(ctx, progressTokenKey{}, token)
+}
+
+// requestMeta returns the request "meta" object: the trace context and the progress token of ctx
+func requestMeta(ctx context.Context) map[string]any {
+	meta := go_jsonrpc.InjectTraceMeta(ctx)
+	if token := ctx.Value(progressTokenKey{}); token != nil {
+		if meta == nil {
+			meta = make(map[string]any)
+		}
+		meta[go_jsonrpc.ProgressTokenMeta] = token
+	}
+	return meta
+}
@@ -1,38 +1,109 @@
 package jclient
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net"
+	"sync"
 	"time"
+
+	"github.com/pablolagos/go-jsonrpc"
 )
 
+// ErrClientClosed is returned by calls pending when the connection is closed
+var ErrClientClosed = errors.New("jclient: connection closed")
+
 // TCPClient is a simple JSON-RPC client over raw TCP
 type TCPClient struct {
-	addr    string
-	timeout time.Duration
+	addr         string
+	timeout      time.Duration
+	tracer       go_jsonrpc.Tracer
+	logger       *slog.Logger
+	codec        go_jsonrpc.Codec
+	notify       func(method string, params json.RawMessage)
+	cancelMethod string
+
+	mu      sync.Mutex // Guards the fields below
+	conn    net.Conn
+	framer  go_jsonrpc.Framer
+	pending map[int]chan Response // Calls waiting for a response, by request ID
+	nextID  int
+	writeMu sync.Mutex // Serializes frames written by concurrent calls
 }
 
 type TCPClientOpts struct {
-	Timeout time.Duration // Timeout for the client
+	Timeout time.Duration     // Timeout for the client
+	Tracer  go_jsonrpc.Tracer // Optional tracer starting a client span per call
+	Logger  *slog.Logger      // Optional logger for calls, including the trace ID
+	// Codec frames messages on a persistent connection shared by concurrent calls.
+	// When nil, each call opens a connection, sends one request and reads the response until EOF.
+	Codec go_jsonrpc.Codec
+	// OnNotification is called, from the connection reader, for notifications pushed by the server
+	// on a persistent connection. It must not block.
+	OnNotification func(method string, params json.RawMessage)
+	// CancelMethod is the notification sent on a persistent connection when a call is abandoned
+	// because its context is done or it timed out. Defaults to go_jsonrpc.DefaultCancelMethod.
+	CancelMethod string
 }
 
 // NewTCPClient creates a new JSON-RPC TCP client
 func NewTCPClient(addr string, opts TCPClientOpts) *TCPClient {
+	if opts.CancelMethod == "" {
+		opts.CancelMethod = go_jsonrpc.DefaultCancelMethod
+	}
 	return &TCPClient{
-		addr:    addr,
-		timeout: opts.Timeout,
+		addr:         addr,
+		timeout:      opts.Timeout,
+		tracer:       opts.Tracer,
+		logger:       opts.Logger,
+		codec:        opts.Codec,
+		notify:       opts.OnNotification,
+		cancelMethod: opts.CancelMethod,
+		pending:      make(map[int]chan Response),
 	}
 }
 
-// Call performs a single JSON-RPC call over TCP and closes the connection
+// Call performs a JSON-RPC call over TCP and decodes into result
 func (c *TCPClient) Call(method string, params interface{}, result interface{}) error {
+	return c.CallContext(context.Background(), method, params, result)
+}
+
+// CallContext performs a JSON-RPC call bound to ctx over TCP and decodes into result.
+// TCP has no headers, so the trace context is sent in the request "meta" object.
+func (c *TCPClient) CallContext(ctx context.Context, method string, params interface{}, result interface{}) (err error) {
+	ctx, finish := startCall(ctx, c.tracer, c.logger, method)
+	defer func() { finish(err) }()
+
+	if c.codec != nil {
+		return c.callPersistent(ctx, method, params, result)
+	}
+	return c.callOnce(ctx, method, params, result)
+}
+
+// Close closes the persistent connection, if any. Pending calls fail with ErrClientClosed.
+func (c *TCPClient) Close() error {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	if conn == nil {
+		return nil
+	}
+	c.closeConn(conn)
+	return nil
+}
+
+// callOnce performs a single call on its own connection and closes it
+func (c *TCPClient) callOnce(ctx context.Context, method string, params interface{}, result interface{}) error {
 	req := Request{
 		JSONRPC: "2.0",
 		Method:  method,
 		Params:  params,
 		ID:      1,
+		Meta:    requestMeta(ctx),
 	}
 
 	data, err := json.Marshal(req)
@@ -41,16 +112,19 @@ func (c *TCPClient) Call(method string, params interface{}, result interface{})
 	}
 
 	// connect
-	conn, err := net.DialTimeout("tcp", c.addr, c.timeout)
+	dialer := net.Dialer{Timeout: c.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", c.addr)
 	if err != nil {
 		return fmt.Errorf("dial: %w", err)
 	}
 	defer conn.Close()
 
 	// set write deadline
-	err = conn.SetWriteDeadline(time.Now().Add(c.timeout))
-	if err != nil {
-		return fmt.Errorf("set write deadline: %w", err)
+	if c.timeout > 0 {
+		err = conn.SetWriteDeadline(time.Now().Add(c.timeout))
+		if err != nil {
+			return fmt.Errorf("set write deadline: %w", err)
+		}
 	}
 
 	if _, err := conn.Write(data); err != nil {
@@ -58,9 +132,11 @@ func (c *TCPClient) Call(method string, params interface{}, result interface{})
 	}
 
 	// read response
-	err = conn.SetReadDeadline(time.Now().Add(c.timeout))
-	if err != nil {
-		return fmt.Errorf("set read deadline: %w", err)
+	if c.timeout > 0 {
+		err = conn.SetReadDeadline(time.Now().Add(c.timeout))
+		if err != nil {
+			return fmt.Errorf("set read deadline: %w", err)
+		}
 	}
 
 	respBytes, err := io.ReadAll(conn)
@@ -74,6 +150,174 @@ func (c *TCPClient) Call(method string, params interface{}, result interface{})
 		return fmt.Errorf("unmarshal response: %w", err)
 	}
 
+	return decodeResult(&rpcResp, result)
+}
+
+// callPersistent performs a call on the shared connection, matching the response by ID
+func (c *TCPClient) callPersistent(ctx context.Context, method string, params interface{}, result interface{}) error {
+	conn, framer, id, ch, err := c.register(ctx)
+	if err != nil {
+		return err
+	}
+
+	req := Request{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  params,
+		ID:      id,
+		Meta:    requestMeta(ctx),
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		c.unregister(id)
+		return fmt.Errorf("marshal request: %w", err)
+	}
+
+	if err := c.writeFrame(conn, framer, data); err != nil {
+		c.closeConn(conn)
+		return fmt.Errorf("write: %w", err)
+	}
+
+	var timeout <-chan time.Time
+	if c.timeout > 0 {
+		timer := time.NewTimer(c.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case rpcResp, ok := <-ch:
+		if !ok {
+			return ErrClientClosed
+		}
+		return decodeResult(&rpcResp, result)
+	case <-ctx.Done():
+		c.unregister(id)
+		c.sendCancel(conn, framer, id)
+		return ctx.Err()
+	case <-timeout:
+		c.unregister(id)
+		c.sendCancel(conn, framer, id)
+		return fmt.Errorf("read: timeout after %v", c.timeout)
+	}
+}
+
+// writeFrame writes one message on the shared connection
+func (c *TCPClient) writeFrame(conn net.Conn, framer go_jsonrpc.Framer, data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.timeout > 0 {
+		_ = conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	}
+	return framer.WriteMessage(data)
+}
+
+// sendCancel tells the server to stop working on an abandoned call
+func (c *TCPClient) sendCancel(conn net.Conn, framer go_jsonrpc.Framer, id int) {
+	// A notification: no request ID
+	data, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"method":  c.cancelMethod,
+		"params":  map[string]int{"id": id},
+	})
+	if err != nil {
+		return
+	}
+	if err := c.writeFrame(conn, framer, data); err != nil && c.logger != nil {
+		c.logger.Warn("failed to send cancel notification", slog.String("addr", c.addr), slog.Any("error", err))
+	}
+}
+
+// register connects if needed and allocates a request ID with its response channel
+func (c *TCPClient) register(ctx context.Context) (net.Conn, go_jsonrpc.Framer, int, chan Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		dialer := net.Dialer{Timeout: c.timeout}
+		conn, err := dialer.DialContext(ctx, "tcp", c.addr)
+		if err != nil {
+			return nil, nil, 0, nil, fmt.Errorf("dial: %w", err)
+		}
+		c.conn = conn
+		c.framer = c.codec.NewFramer(conn)
+		go c.readLoop(conn, c.framer)
+	}
+
+	c.nextID++
+	ch := make(chan Response, 1)
+	c.pending[c.nextID] = ch
+	return c.conn, c.framer, c.nextID, ch, nil
+}
+
+func (c *TCPClient) unregister(id int) {
+	c.mu.Lock()
+	delete(c.pending, id)
+	c.mu.Unlock()
+}
+
+// readLoop delivers the responses read from conn to the pending calls
+func (c *TCPClient) readLoop(conn net.Conn, framer go_jsonrpc.Framer) {
+	defer c.closeConn(conn)
+	for {
+		msg, err := framer.ReadMessage()
+		if err != nil {
+			if c.logger != nil && !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				c.logger.Warn("connection read failed", slog.String("addr", c.addr), slog.Any("error", err))
+			}
+			return
+		}
+
+		var rpcResp incoming
+		if err := json.Unmarshal(msg, &rpcResp); err != nil {
+			if c.logger != nil {
+				c.logger.Warn("invalid response", slog.String("addr", c.addr), slog.Any("error", err))
+			}
+			continue
+		}
+
+		// Notification pushed by the server
+		if rpcResp.Method != "" {
+			if c.notify != nil {
+				c.notify(rpcResp.Method, rpcResp.Params)
+			}
+			continue
+		}
+
+		c.mu.Lock()
+		ch, ok := c.pending[rpcResp.ID]
+		delete(c.pending, rpcResp.ID)
+		c.mu.Unlock()
+		if ok {
+			ch <- rpcResp.Response
+		}
+	}
+}
+
+// closeConn closes conn and fails the calls pending on it
+func (c *TCPClient) closeConn(conn net.Conn) {
+	c.mu.Lock()
+	if c.conn == conn {
+		c.conn = nil
+		c.framer = nil
+		for id, ch := range c.pending {
+			close(ch)
+			delete(c.pending, id)
+		}
+	}
+	c.mu.Unlock()
+	conn.Close()
+}
+
+// incoming is a message read on a persistent connection: a response or a server notification
+type incoming struct {
+	Response
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+// decodeResult converts a response into an error or decodes its result
+func decodeResult(rpcResp *Response, result interface{}) error {
 	if rpcResp.Error != nil {
 		return fmt.Errorf("rpc error %d: %s", rpcResp.Error.Code, rpcResp.Error.Message)
 	}
@@ -0,0 +1,47 @@
+package jclient
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/pablolagos/go-jsonrpc"
+)
+
+// startCall prepares the trace context of an outgoing call.
+// A client span is started when a tracer is set; otherwise the span context found in ctx is used,
+// and a new trace is started when there is none, so both sides always log the same trace ID.
+// The returned function finishes the span and logs the call.
+func startCall(ctx context.Context, tracer go_jsonrpc.Tracer, logger *slog.Logger, method string) (context.Context, func(error)) {
+	var span go_jsonrpc.Span
+	if tracer != nil {
+		ctx, span = tracer.Start(ctx, method)
+		span.SetAttributes(
+			go_jsonrpc.Attribute{Key: go_jsonrpc.AttrRPCSystem, Value: "jsonrpc"},
+			go_jsonrpc.Attribute{Key: go_jsonrpc.AttrRPCMethod, Value: method},
+		)
+	}
+	if !go_jsonrpc.SpanContextFromContext(ctx).IsValid() {
+		ctx = go_jsonrpc.ContextWithRemoteSpanContext(ctx, go_jsonrpc.NewSpanContext())
+	}
+
+	start := time.Now()
+	traceID := go_jsonrpc.SpanContextFromContext(ctx).TraceIDString()
+	return ctx, func(err error) {
+		if span != nil {
+			if err != nil {
+				span.SetError(err)
+			}
+			span.End()
+		}
+		if logger == nil {
+			return
+		}
+		attrs := []any{slog.String("method", method), slog.String("trace_id", traceID), slog.Duration("duration", time.Since(start))}
+		if err != nil {
+			logger.Warn("rpc call failed", append(attrs, slog.Any("error", err))...)
+			return
+		}
+		logger.Debug("rpc call", attrs...)
+	}
+}
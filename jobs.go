@@ -0,0 +1,382 @@
+// jobs.go
+package go_jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// DefaultJobWorkers is the size of the worker pool when Options.JobWorkers is 0
+const DefaultJobWorkers = 4
+
+// DefaultJobQueueSize is the number of jobs waiting for a worker when Options.JobQueueSize is 0
+const DefaultJobQueueSize = 1000
+
+// jobs runs asynchronous commands in a worker pool
+type jobs struct {
+	server  *JsRPC
+	store   JobStore
+	queue   chan *jobTask
+	cancels map[string]context.CancelFunc // Jobs queued or running in this process
+	idle    chan struct{}                 // Closed when the last job of this process finishes, while WaitJobs waits
+
+	middlewares map[string][]MiddlewareFunc // Middlewares of each async command, also run by the job methods
+	mu          sync.Mutex
+}
+
+// jobTask is a job waiting for a worker
+type jobTask struct {
+	job     *Job
+	ctx     *Context
+	handler HandlerFunc
+}
+
+// jobParams are the params of the job methods, given as {"id": "..."} or ["..."]
+type jobParams struct {
+	ID string `json:"id"`
+}
+
+// RegisterAsyncCommand registers a command executed asynchronously. The call is answered right away with
+// {"jobId": "..."}, after the middlewares have run, and the handler runs later in a worker pool;
+// what it writes with ctx.JSON or ctx.Error is stored as the job's result.
+//
+// Clients follow the job with the built-in methods, which take {"id": jobId}:
+//
+//   - "job.status" returns the Job without its result
+//   - "job.result" returns the job's result, its error, or a JobNotFinished error
+//   - "job.cancel" cancels the job's context and returns its status
+//
+// The job methods run the middlewares of the command that submitted the job, so a job is only
+// reachable by the clients allowed to submit it.
+//
+// Jobs are kept in Options.JobStore, by default in memory for DefaultJobTTL after they finish.
+func (r *JsRPC) RegisterAsyncCommand(commandName string, handler HandlerFunc, middlewares ...MiddlewareFunc) {
+	js := r.asyncJobs()
+	js.mu.Lock()
+	js.middlewares[commandName] = middlewares
+	js.mu.Unlock()
+	r.RegisterCommand(commandName, func(ctx *Context) error {
+		job, err := js.submit(ctx, handler)
+		if err != nil {
+			return ctx.ErrorString(InternalError, err.Error())
+		}
+		return ctx.JSON(map[string]string{"jobId": job.ID})
+	}, middlewares...)
+}
+
+// asyncJobs returns the job runner, starting it and registering the job methods on first use
+func (r *JsRPC) asyncJobs() *jobs {
+	r.jobsOnce.Do(func() {
+		store := r.options.JobStore
+		if store == nil {
+			store = NewMemoryJobStore(0)
+		}
+		queueSize := r.options.JobQueueSize
+		if queueSize <= 0 {
+			queueSize = DefaultJobQueueSize
+		}
+		workers := r.options.JobWorkers
+		if workers <= 0 {
+			workers = DefaultJobWorkers
+		}
+
+		r.jobs = &jobs{
+			server:  r,
+			store:   store,
+			queue:   make(chan *jobTask, queueSize),
+			cancels: make(map[string]context.CancelFunc),
+
+			middlewares: make(map[string][]MiddlewareFunc),
+		}
+		for i := 0; i < workers; i++ {
+			go r.jobs.work()
+		}
+
+		r.RegisterCommand("job.status", r.jobs.handleStatus)
+		r.RegisterCommand("job.result", r.jobs.handleResult)
+		r.RegisterCommand("job.cancel", r.jobs.handleCancel)
+	})
+	return r.jobs
+}
+
+// submit saves a new job and queues it
+func (js *jobs) submit(ctx *Context, handler HandlerFunc) (*Job, error) {
+	job := &Job{
+		ID:        newPeerID(),
+		Method:    ctx.Method,
+		State:     JobQueued,
+		CreatedAt: time.Now(),
+	}
+
+	// The job outlives the request: keep its values (trace, logger) but not its cancellation
+	stdctx, cancel := context.WithCancel(context.WithoutCancel(ctx.Context()))
+	jobCtx := &Context{
+		Method:     ctx.Method,
+		Params:     ctx.Params,
+		ID:         ctx.ID,
+		RemoteAddr: ctx.RemoteAddr,
+		writer:     &bytes.Buffer{},
+		data:       ctx.data,
+		meta:       ctx.meta,
+		Logger:     ctx.Logger,
+		Slog:       ctx.Slog.With(slog.String("job", job.ID)),
+		server:     ctx.server,
+		stdctx:     stdctx,
+	}
+
+	if err := js.store.Save(job); err != nil {
+		cancel()
+		return nil, fmt.Errorf("save job: %w", err)
+	}
+	js.mu.Lock()
+	js.cancels[job.ID] = cancel
+	js.mu.Unlock()
+
+	select {
+	case js.queue <- &jobTask{job: job, ctx: jobCtx, handler: handler}:
+		return job, nil
+	default:
+		js.forget(job.ID)
+		_ = js.store.Delete(job.ID)
+		return nil, errors.New("job queue is full")
+	}
+}
+
+// work runs queued jobs until the process exits
+func (js *jobs) work() {
+	for task := range js.queue {
+		js.run(task)
+	}
+}
+
+// run executes a job and stores its outcome
+func (js *jobs) run(task *jobTask) {
+	job, ctx := task.job, task.ctx
+	defer js.forget(job.ID)
+
+	// Skipped when cancelled while queued
+	started := js.update(task, func() {
+		now := time.Now()
+		job.State = JobRunning
+		job.StartedAt = &now
+	})
+	if !started {
+		return
+	}
+
+	var err error
+	func() {
+		defer func() {
+			if p := recover(); p != nil {
+				ctx.Slog.Error("job panic", slog.Any("panic", p), slog.String("stack", string(debug.Stack())))
+				err = fmt.Errorf("panic: %v", p)
+			}
+		}()
+		err = task.handler(ctx)
+	}()
+	if ctx.session != nil {
+		ctx.session.close()
+	}
+
+	var response struct {
+		Result json.RawMessage `json:"result"`
+		Error  *JSONRPCError   `json:"error"`
+	}
+	switch {
+	case ctx.responded:
+		if decodeErr := json.Unmarshal(ctx.writer.(*bytes.Buffer).Bytes(), &response); decodeErr != nil {
+			response.Error = &JSONRPCError{Code: InternalError, Message: "invalid job result"}
+		}
+	case err != nil:
+		response.Error = &JSONRPCError{Code: InternalError, Message: err.Error()}
+	}
+	if err != nil && ctx.stdctx.Err() == nil {
+		ctx.Slog.Error("job error", slog.Any("error", err))
+	}
+
+	// Not stored when cancelled while running: job.cancel already stored the final state
+	js.update(task, func() {
+		finished := time.Now()
+		job.FinishedAt = &finished
+		job.Result, job.Error = response.Result, response.Error
+		job.State = JobSucceeded
+		if job.Error != nil {
+			job.State = JobFailed
+		}
+	})
+}
+
+// update changes and saves a job unless it has been cancelled, reporting whether it did.
+// It is serialized with job.cancel so that a cancelled job keeps its state.
+func (js *jobs) update(task *jobTask, change func()) bool {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	if task.ctx.stdctx.Err() != nil {
+		return false
+	}
+	change()
+	js.save(task.job)
+	return true
+}
+
+func (js *jobs) save(job *Job) {
+	if err := js.store.Save(job); err != nil {
+		js.server.slog.Error("failed to save job", slog.String("job", job.ID), slog.Any("error", err))
+	}
+}
+
+// forget releases the cancel func of a job that is no longer running
+func (js *jobs) forget(id string) {
+	js.mu.Lock()
+	cancel, ok := js.cancels[id]
+	delete(js.cancels, id)
+	if len(js.cancels) == 0 && js.idle != nil {
+		close(js.idle)
+		js.idle = nil
+	}
+	js.mu.Unlock()
+	if ok {
+		cancel()
+	}
+}
+
+// pending reports whether jobs are queued or running in this process
+func (js *jobs) pending() bool {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	return len(js.cancels) > 0
+}
+
+// WaitJobs waits until the jobs queued or running in this process have finished, or ctx is done.
+// ServeCGI calls it once the response is written, since the process exits when it returns;
+// call it after ExecuteCommand when serving CGI with Options.CGI.
+func (r *JsRPC) WaitJobs(ctx context.Context) error {
+	js := r.jobs
+	if js == nil {
+		return nil
+	}
+	js.mu.Lock()
+	if len(js.cancels) == 0 {
+		js.mu.Unlock()
+		return nil
+	}
+	if js.idle == nil {
+		js.idle = make(chan struct{})
+	}
+	idle := js.idle
+	js.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// lookup reads the job named in the params and runs the middlewares of the command that submitted it.
+// It returns a nil job when the request was answered with an error, and the error of a middleware
+// that stopped the request.
+func (js *jobs) lookup(ctx *Context) (*Job, error) {
+	var params jobParams
+	var list []string
+	if err := ctx.Bind(&list); err == nil && len(list) == 1 {
+		params.ID = list[0]
+	} else if err := ctx.Bind(&params); err != nil || params.ID == "" {
+		_ = ctx.ErrorString(InvalidParams, `expected {"id": jobId}`)
+		return nil, nil
+	}
+
+	job, err := js.store.Get(params.ID)
+	if errors.Is(err, ErrJobNotFound) {
+		_ = ctx.ErrorString(JobNotFound, "job not found")
+		return nil, nil
+	}
+	if err != nil {
+		_ = ctx.ErrorString(InternalError, err.Error())
+		return nil, nil
+	}
+
+	js.mu.Lock()
+	middlewares, ok := js.middlewares[job.Method]
+	js.mu.Unlock()
+	if !ok {
+		// Submitted by a command this process does not serve
+		_ = ctx.ErrorString(JobNotFound, "job not found")
+		return nil, nil
+	}
+	for i, middleware := range middlewares {
+		if err := js.server.tracedCall(ctx, "middleware", middleware, Attribute{Key: AttrScope, Value: "command"}, Attribute{Key: AttrMiddleware, Value: i}); err != nil {
+			return nil, err
+		}
+	}
+	return job, nil
+}
+
+// handleStatus implements job.status
+func (js *jobs) handleStatus(ctx *Context) error {
+	job, err := js.lookup(ctx)
+	if job == nil {
+		return err
+	}
+	job.Result, job.Error = nil, nil
+	return ctx.JSON(job)
+}
+
+// handleResult implements job.result
+func (js *jobs) handleResult(ctx *Context) error {
+	job, err := js.lookup(ctx)
+	if job == nil {
+		return err
+	}
+	switch job.State {
+	case JobSucceeded:
+		return ctx.JSON(job.Result)
+	case JobFailed:
+		return ctx.writeResponse(JSONRPCResponse{JSONRPC: "2.0", Error: job.Error, ID: ctx.ID})
+	case JobCancelled:
+		return ctx.ErrorString(RequestCancelled, "job cancelled")
+	default:
+		return ctx.ErrorString(JobNotFinished, "job not finished")
+	}
+}
+
+// handleCancel implements job.cancel
+func (js *jobs) handleCancel(ctx *Context) error {
+	job, err := js.lookup(ctx)
+	if job == nil {
+		return err
+	}
+	if !job.State.Finished() {
+		js.mu.Lock()
+		cancel, running := js.cancels[job.ID]
+		if running {
+			now := time.Now()
+			job.State = JobCancelled
+			job.FinishedAt = &now
+			js.save(job)
+			cancel()
+		}
+		js.mu.Unlock()
+
+		if !running {
+			// Finished in the meantime, or run by another process sharing the store
+			var err error
+			if job, err = js.store.Get(job.ID); err != nil {
+				return ctx.ErrorString(InternalError, err.Error())
+			}
+			if !job.State.Finished() {
+				return ctx.ErrorString(InternalError, "job is not running in this process")
+			}
+		}
+	}
+	job.Result, job.Error = nil, nil
+	return ctx.JSON(job)
+}
@@ -0,0 +1,133 @@
+package go_jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+// callJob sends a request over HTTP and decodes its result into out, failing on a transport error
+func callJob(t *testing.T, r *JsRPC, method string, params any, out any) *JSONRPCError {
+	t.Helper()
+	body, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "method": method, "params": params, "id": 1})
+	_, res := postJSON(t, r, string(body))
+	if res == nil {
+		t.Fatalf("%s: no response", method)
+	}
+	if res.Error != nil {
+		return res.Error
+	}
+	if out != nil {
+		raw, _ := json.Marshal(res.Result)
+		if err := json.Unmarshal(raw, out); err != nil {
+			t.Fatalf("%s: invalid result %s: %v", method, raw, err)
+		}
+	}
+	return nil
+}
+
+func TestJobLifecycle(t *testing.T) {
+	r := New(quietOptions())
+	release := make(chan struct{})
+	r.RegisterAsyncCommand("slow", func(ctx *Context) error {
+		select {
+		case <-release:
+			return ctx.JSON("done")
+		case <-ctx.Context().Done():
+			return ctx.Context().Err()
+		}
+	})
+
+	// Submit two jobs: one completes, the other is cancelled
+	var submitted, other struct {
+		JobID string `json:"jobId"`
+	}
+	if err := callJob(t, r, "slow", nil, &submitted); err != nil || submitted.JobID == "" {
+		t.Fatalf("submit: %v %q", err, submitted.JobID)
+	}
+	if err := callJob(t, r, "slow", nil, &other); err != nil || other.JobID == "" {
+		t.Fatalf("submit: %v %q", err, other.JobID)
+	}
+
+	var job Job
+	if err := callJob(t, r, "job.status", map[string]string{"id": submitted.JobID}, &job); err != nil {
+		t.Fatal(err)
+	}
+	if job.ID != submitted.JobID || job.Method != "slow" || job.State.Finished() {
+		t.Fatalf("got %+v, want an unfinished slow job", job)
+	}
+	if err := callJob(t, r, "job.result", []string{submitted.JobID}, nil); err == nil || err.Code != JobNotFinished {
+		t.Fatalf("got %v, want JobNotFinished", err)
+	}
+
+	if err := callJob(t, r, "job.cancel", map[string]string{"id": other.JobID}, &job); err != nil || job.State != JobCancelled {
+		t.Fatalf("cancel: %v %+v", err, job)
+	}
+	if err := callJob(t, r, "job.result", map[string]string{"id": other.JobID}, nil); err == nil || err.Code != RequestCancelled {
+		t.Fatalf("got %v, want RequestCancelled", err)
+	}
+
+	close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := r.WaitJobs(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var result string
+	if err := callJob(t, r, "job.result", map[string]string{"id": submitted.JobID}, &result); err != nil || result != "done" {
+		t.Fatalf("result: %v %q", err, result)
+	}
+	if err := callJob(t, r, "job.status", map[string]string{"id": submitted.JobID}, &job); err != nil || job.State != JobSucceeded || job.Result != nil {
+		t.Fatalf("status: %v %+v", err, job)
+	}
+}
+
+func TestJobErrors(t *testing.T) {
+	r := New(quietOptions())
+	allowed := true
+	r.RegisterAsyncCommand("fail", func(ctx *Context) error {
+		return ctx.ErrorString(1000, "job failed")
+	}, func(ctx *Context) error {
+		if !allowed {
+			return errors.New("forbidden")
+		}
+		return nil
+	})
+
+	var submitted struct {
+		JobID string `json:"jobId"`
+	}
+	if err := callJob(t, r, "fail", nil, &submitted); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.WaitJobs(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		params   any
+		denied   bool // The middleware of the command rejects the request
+		wantCode int
+	}{
+		{"failed job", "job.result", map[string]string{"id": submitted.JobID}, false, 1000},
+		{"unknown job", "job.status", map[string]string{"id": "missing"}, false, JobNotFound},
+		{"missing id", "job.status", map[string]string{}, false, InvalidParams},
+		{"status rejected by middleware", "job.status", map[string]string{"id": submitted.JobID}, true, InternalError},
+		{"result rejected by middleware", "job.result", map[string]string{"id": submitted.JobID}, true, InternalError},
+		{"cancel rejected by middleware", "job.cancel", map[string]string{"id": submitted.JobID}, true, InternalError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed = !tt.denied
+			defer func() { allowed = true }()
+			err := callJob(t, r, tt.method, tt.params, nil)
+			if err == nil || err.Code != tt.wantCode {
+				t.Fatalf("got %v, want error code %d", err, tt.wantCode)
+			}
+		})
+	}
+}
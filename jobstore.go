@@ -0,0 +1,116 @@
+// jobstore.go
+package go_jsonrpc
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrJobNotFound is returned by a JobStore for unknown or expired jobs
+var ErrJobNotFound = errors.New("job not found")
+
+// DefaultJobTTL is how long finished jobs are kept by the default job store
+const DefaultJobTTL = time.Hour
+
+// JobState is the state of an asynchronous job
+type JobState string
+
+const (
+	JobQueued    JobState = "queued"
+	JobRunning   JobState = "running"
+	JobSucceeded JobState = "succeeded"
+	JobFailed    JobState = "failed"
+	JobCancelled JobState = "cancelled"
+)
+
+// Finished reports whether the job will not change anymore
+func (s JobState) Finished() bool {
+	return s == JobSucceeded || s == JobFailed || s == JobCancelled
+}
+
+// Job is an asynchronous command execution
+type Job struct {
+	ID         string          `json:"id"`
+	Method     string          `json:"method"`
+	State      JobState        `json:"state"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	StartedAt  *time.Time      `json:"startedAt,omitempty"`
+	FinishedAt *time.Time      `json:"finishedAt,omitempty"`
+	Result     json.RawMessage `json:"result,omitempty"` // Result of a succeeded job
+	Error      *JSONRPCError   `json:"error,omitempty"`  // Error of a failed job
+}
+
+// JobStore keeps the state and results of asynchronous jobs. Implement it to persist jobs,
+// e.g. to share them between processes. Implementations must be safe for concurrent use.
+type JobStore interface {
+	Save(job *Job) error         // Creates or replaces a job
+	Get(id string) (*Job, error) // Returns a copy of a job, or ErrJobNotFound
+	Delete(id string) error      // Removes a job
+}
+
+// MemoryJobStore is an in-memory JobStore that forgets finished jobs after a TTL
+type MemoryJobStore struct {
+	ttl       time.Duration
+	mu        sync.Mutex
+	jobs      map[string]*Job
+	lastPrune time.Time
+}
+
+// NewMemoryJobStore creates an in-memory job store keeping finished jobs for ttl.
+// A ttl of 0 means DefaultJobTTL.
+func NewMemoryJobStore(ttl time.Duration) *MemoryJobStore {
+	if ttl <= 0 {
+		ttl = DefaultJobTTL
+	}
+	return &MemoryJobStore{ttl: ttl, jobs: make(map[string]*Job), lastPrune: time.Now()}
+}
+
+// Save creates or replaces a job
+func (s *MemoryJobStore) Save(job *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	copied := *job
+	s.jobs[job.ID] = &copied
+	s.prune()
+	return nil
+}
+
+// Get returns a copy of a job, or ErrJobNotFound
+func (s *MemoryJobStore) Get(id string) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok || s.expired(job, time.Now()) {
+		return nil, ErrJobNotFound
+	}
+	copied := *job
+	return &copied, nil
+}
+
+// Delete removes a job
+func (s *MemoryJobStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.jobs, id)
+	return nil
+}
+
+func (s *MemoryJobStore) expired(job *Job, now time.Time) bool {
+	return job.FinishedAt != nil && now.Sub(*job.FinishedAt) > s.ttl
+}
+
+// prune removes the expired jobs, at most once per TTL
+func (s *MemoryJobStore) prune() {
+	now := time.Now()
+	if now.Sub(s.lastPrune) < s.ttl {
+		return
+	}
+	s.lastPrune = now
+	for id, job := range s.jobs {
+		if s.expired(job, now) {
+			delete(s.jobs, id)
+		}
+	}
+}
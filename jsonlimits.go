@@ -0,0 +1,153 @@
+// jsonlimits.go
+package go_jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrMessageTooLarge is returned when a message exceeds Options.MaxMessageBytes,
+// or DefaultMaxFrameBytes on a framed stream without limit
+var ErrMessageTooLarge = errors.New("message too large")
+
+// errInvalidRequest is returned for valid JSON that is not a request object.
+// Batches (top-level arrays) are not supported and are rejected the same way.
+var errInvalidRequest = errors.New("invalid request")
+
+// limitError is a request rejected by the size and complexity limits of Options
+type limitError struct {
+	reason string
+	id     any // ID of the request, when it could be read
+}
+
+func (e *limitError) Error() string {
+	return e.reason
+}
+
+// messageLimiter is implemented by the framers of this package, to enforce Options.MaxMessageBytes
+// while reading a message rather than after
+type messageLimiter interface {
+	setMaxMessageBytes(n int64)
+}
+
+// decodeRequest decodes a request, enforcing the size and complexity limits of Options
+// before the request is decoded into Go values
+func (r *JsRPC) decodeRequest(reader io.Reader, req *JSONRPCRequest) error {
+	o := r.options
+	if o.MaxMessageBytes > 0 {
+		reader = &maxBytesReader{reader: reader, remaining: o.MaxMessageBytes}
+	}
+	var raw json.RawMessage
+	if err := json.NewDecoder(reader).Decode(&raw); err != nil {
+		if errors.Is(err, ErrMessageTooLarge) {
+			return &limitError{reason: fmt.Sprintf("request exceeds %d bytes", o.MaxMessageBytes)}
+		}
+		return err
+	}
+	if o.MaxMessageBytes > 0 && int64(len(raw)) > o.MaxMessageBytes {
+		return &limitError{reason: fmt.Sprintf("request exceeds %d bytes", o.MaxMessageBytes)}
+	}
+	if raw[0] != '{' {
+		return errInvalidRequest
+	}
+
+	if o.MaxDepth <= 0 && o.MaxParamsKeys <= 0 {
+		return json.Unmarshal(raw, req)
+	}
+	if reason := r.checkComplexity(raw); reason != "" {
+		var probe struct {
+			ID any `json:"id"`
+		}
+		_ = json.Unmarshal(raw, &probe)
+		return &limitError{reason: reason, id: probe.ID}
+	}
+	return json.Unmarshal(raw, req)
+}
+
+// jsonFrame is an object or array being scanned by checkComplexity
+type jsonFrame struct {
+	object    bool // An object, otherwise an array
+	expectKey bool // The next token of the object is a key
+	members   int  // Values read so far
+	params    bool // The params of a request
+}
+
+// checkComplexity scans the tokens of a message, returning why it exceeds the limits, or ""
+func (r *JsRPC) checkComplexity(raw []byte) string {
+	o := r.options
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+
+	var stack []jsonFrame
+	paramsNext := false // The next value is the params of a request
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return "" // End of the message, or a syntax error reported by the decoder
+		}
+
+		var parent *jsonFrame
+		if len(stack) > 0 {
+			parent = &stack[len(stack)-1]
+		}
+
+		// Object keys
+		if parent != nil && parent.object && parent.expectKey {
+			if key, ok := tok.(string); ok {
+				parent.expectKey = false
+				paramsNext = len(stack) == 1 && key == "params"
+				continue
+			}
+		}
+
+		// End of an object or array: it was counted as a member of its parent when opened
+		if tok == json.Delim('}') || tok == json.Delim(']') {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		// A value, member of its parent
+		if parent != nil {
+			parent.members++
+			parent.expectKey = parent.object
+			if parent.params && o.MaxParamsKeys > 0 && parent.members > o.MaxParamsKeys {
+				return fmt.Sprintf("params exceed %d members", o.MaxParamsKeys)
+			}
+		}
+
+		if tok == json.Delim('{') || tok == json.Delim('[') {
+			stack = append(stack, jsonFrame{
+				object:    tok == json.Delim('{'),
+				expectKey: tok == json.Delim('{'),
+				params:    paramsNext,
+			})
+			if o.MaxDepth > 0 && len(stack) > o.MaxDepth {
+				return fmt.Sprintf("request nesting exceeds depth %d", o.MaxDepth)
+			}
+		}
+		paramsNext = false
+	}
+}
+
+// maxBytesReader fails once more than a number of bytes have been read.
+// The error is returned on the read after the limit is crossed, so that a decoder
+// reading ahead past a message that fits does not fail.
+type maxBytesReader struct {
+	reader    io.Reader
+	remaining int64
+}
+
+func (m *maxBytesReader) Read(p []byte) (int, error) {
+	if m.remaining < 0 {
+		return 0, ErrMessageTooLarge
+	}
+	if int64(len(p)) > m.remaining+1 {
+		p = p[:m.remaining+1]
+	}
+	n, err := m.reader.Read(p)
+	m.remaining -= int64(n)
+	return n, err
+}
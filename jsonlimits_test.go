@@ -0,0 +1,78 @@
+package go_jsonrpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// postJSON sends a JSON-RPC request to r over HTTP and decodes the response, if any
+func postJSON(t *testing.T, r *JsRPC, body string) (int, *JSONRPCResponse) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Body.Len() == 0 {
+		return rec.Code, nil
+	}
+	var res JSONRPCResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatalf("invalid response %q: %v", rec.Body.String(), err)
+	}
+	return rec.Code, &res
+}
+
+func TestRequestLimits(t *testing.T) {
+	options := quietOptions()
+	options.MaxMessageBytes = 200
+	options.MaxDepth = 4
+	options.MaxParamsKeys = 3
+	r := New(options)
+	r.RegisterCommand("echo", func(ctx *Context) error {
+		return ctx.JSON(ctx.Params)
+	})
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantCode   int // 0 for a result
+	}{
+		{"within limits", `{"jsonrpc":"2.0","method":"echo","params":{"a":[1,2]},"id":1}`, http.StatusOK, 0},
+		{"too large", `{"jsonrpc":"2.0","method":"echo","params":["` + strings.Repeat("a", 200) + `"],"id":1}`, http.StatusRequestEntityTooLarge, RequestTooLarge},
+		{"too deep", `{"jsonrpc":"2.0","method":"echo","params":[[[[1]]]],"id":1}`, http.StatusRequestEntityTooLarge, RequestTooLarge},
+		{"too many params", `{"jsonrpc":"2.0","method":"echo","params":[1,2,3,4],"id":1}`, http.StatusRequestEntityTooLarge, RequestTooLarge},
+		{"too many param keys", `{"jsonrpc":"2.0","method":"echo","params":{"a":1,"b":2,"c":3,"d":4},"id":1}`, http.StatusRequestEntityTooLarge, RequestTooLarge},
+		{"batch", `[{"jsonrpc":"2.0","method":"echo","id":1}]`, http.StatusBadRequest, InvalidRequest},
+		{"empty batch", `[]`, http.StatusBadRequest, InvalidRequest},
+		{"not an object", `42`, http.StatusBadRequest, InvalidRequest},
+		{"invalid JSON", `{"jsonrpc":`, http.StatusBadRequest, ParseError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, res := postJSON(t, r, tt.body)
+			if status != tt.wantStatus {
+				t.Fatalf("got status %d, want %d", status, tt.wantStatus)
+			}
+			switch {
+			case res == nil:
+				t.Fatal("no response")
+			case tt.wantCode == 0 && res.Error != nil:
+				t.Fatalf("got error %v, want a result", res.Error)
+			case tt.wantCode != 0 && (res.Error == nil || res.Error.Code != tt.wantCode):
+				t.Fatalf("got %+v, want error code %d", res, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestBatchWithoutLimits(t *testing.T) {
+	r := New(quietOptions())
+	status, res := postJSON(t, r, `[{"jsonrpc":"2.0","method":"echo","id":1}]`)
+	if status != http.StatusBadRequest || res == nil || res.Error == nil || res.Error.Code != InvalidRequest {
+		t.Fatalf("got %d %+v, want an InvalidRequest error", status, res)
+	}
+}
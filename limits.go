@@ -0,0 +1,109 @@
+// limits.go
+package go_jsonrpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+// limits enforces the concurrency limits of Options. A nil channel means no limit.
+type limits struct {
+	conns   chan struct{} // Slots of connections accepted by listeners
+	workers chan struct{} // Slots of requests being executed
+}
+
+func newLimits(options *Options) *limits {
+	l := &limits{}
+	if options.MaxConnections > 0 {
+		l.conns = make(chan struct{}, options.MaxConnections)
+	}
+	if options.MaxWorkers > 0 {
+		l.workers = make(chan struct{}, options.MaxWorkers)
+	}
+	return l
+}
+
+// Stats is a snapshot of the server usage and limits, for monitoring
+type Stats struct {
+	Connections         int64  // Open connections, from listeners and WebSocket handlers
+	MaxConnections      int    // Options.MaxConnections, 0 when unlimited
+	RejectedConnections uint64 // Connections closed because MaxConnections was reached
+	Peers               int    // Persistent connections
+	InFlight            int64  // Requests being dispatched
+	RejectedRequests    uint64 // Requests refused because MaxInFlightPerConn was reached
+	BusyWorkers         int64  // Requests holding a worker
+	MaxWorkers          int    // Options.MaxWorkers, 0 when unlimited
+}
+
+// Stats returns the current usage of the server
+func (r *JsRPC) Stats() Stats {
+	r.peersMu.RLock()
+	peers := len(r.peers)
+	r.peersMu.RUnlock()
+
+	return Stats{
+		Connections:         r.metrics.activeConns.Load(),
+		MaxConnections:      cap(r.limits.conns),
+		RejectedConnections: r.metrics.rejectedConns.Load(),
+		Peers:               peers,
+		InFlight:            r.metrics.inFlight.Load(),
+		RejectedRequests:    r.metrics.rejectedRequests.Load(),
+		BusyWorkers:         r.metrics.busyWorkers.Load(),
+		MaxWorkers:          cap(r.limits.workers),
+	}
+}
+
+// acquireWorker waits for a free worker, or for ctx to be done.
+// The returned function releases the worker.
+func (r *JsRPC) acquireWorker(ctx context.Context) (func(), error) {
+	if r.limits.workers != nil {
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		select {
+		case r.limits.workers <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	r.metrics.busyWorkers.Add(1)
+	return func() {
+		r.metrics.busyWorkers.Add(-1)
+		if r.limits.workers != nil {
+			<-r.limits.workers
+		}
+	}, nil
+}
+
+// listenerPollInterval is how often a listener waiting for a connection slot is checked for closure
+const listenerPollInterval = 250 * time.Millisecond
+
+// waitConnSlot takes a connection slot. It returns false if the listener is closed while waiting.
+// Closure is detected on listeners with a SetDeadline method, such as TCP and Unix listeners;
+// on others, the wait lasts until a slot is free.
+func waitConnSlot(listener net.Listener, slots chan struct{}) bool {
+	select {
+	case slots <- struct{}{}:
+		return true
+	default:
+	}
+	deadliner, ok := listener.(interface{ SetDeadline(time.Time) error })
+	if !ok {
+		slots <- struct{}{}
+		return true
+	}
+	ticker := time.NewTicker(listenerPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case slots <- struct{}{}:
+			return true
+		case <-ticker.C:
+			if err := deadliner.SetDeadline(time.Time{}); errors.Is(err, net.ErrClosed) {
+				return false
+			}
+		}
+	}
+}
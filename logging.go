@@ -0,0 +1,109 @@
+// logging.go
+package go_jsonrpc
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// defaultLogOutput is the output of the default logger: stdout, until a transport reserves stdout
+// for its protocol (stdio, CGI) and the logs move to stderr
+type defaultLogOutput struct {
+	stderr atomic.Bool
+}
+
+func (o *defaultLogOutput) Write(p []byte) (int, error) {
+	if o.stderr.Load() {
+		return os.Stderr.Write(p)
+	}
+	return os.Stdout.Write(p)
+}
+
+// logToStderr moves the default logger to stderr, as stdout carries the responses
+func (r *JsRPC) logToStderr() {
+	if r.logOutput != nil {
+		r.logOutput.stderr.Store(true)
+	}
+}
+
+// newPrintfSlogger wraps a Printf-style Logger into a *slog.Logger.
+// Records are rendered in the slog text format (without the time attribute,
+// which the wrapped logger usually adds itself) and handed to Printf.
+func newPrintfSlogger(logger Logger, level slog.Leveler) *slog.Logger {
+	return slog.New(slog.NewTextHandler(printfWriter{logger: logger}, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+}
+
+// printfWriter forwards each formatted slog record to a Printf-style Logger
+type printfWriter struct {
+	logger Logger
+}
+
+func (w printfWriter) Write(p []byte) (int, error) {
+	w.logger.Printf("%s", strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
+
+// slogPrinter adapts a *slog.Logger to the Printf-style Logger interface,
+// so Context.Logger keeps working when only a structured logger is configured.
+type slogPrinter struct {
+	logger *slog.Logger
+}
+
+func (p slogPrinter) Printf(format string, v ...interface{}) {
+	p.logger.Info(fmt.Sprintf(format, v...))
+}
+
+func (p slogPrinter) Println(v ...interface{}) {
+	p.logger.Info(strings.TrimSuffix(fmt.Sprintln(v...), "\n"))
+}
+
+// requestLogger returns a logger carrying the request-scoped attributes
+func (r *JsRPC) requestLogger(ctx *Context) *slog.Logger {
+	attrs := []any{slog.String("method", ctx.Method)}
+	if ctx.ID != nil {
+		attrs = append(attrs, slog.Any("id", ctx.ID))
+	}
+	if ctx.RemoteAddr != "" {
+		attrs = append(attrs, slog.String("remote", ctx.RemoteAddr))
+	}
+	if sc := SpanContextFromContext(ctx.Context()); sc.IsValid() {
+		attrs = append(attrs, slog.String("trace_id", sc.TraceIDString()))
+	}
+	return r.slog.With(attrs...)
+}
+
+// logRequest emits the per-request log line once the request has been dispatched.
+// Requests are logged at debug level; with LogRequests they are raised to info and include the params.
+func (r *JsRPC) logRequest(ctx *Context, rpcRequest *JSONRPCRequest, elapsed time.Duration) {
+	level := slog.LevelDebug
+	if r.options.LogRequests {
+		level = slog.LevelInfo
+	}
+	if !ctx.Slog.Enabled(context.Background(), level) {
+		return
+	}
+	attrs := []slog.Attr{slog.Duration("duration", elapsed)}
+	if ctx.errorCode != 0 {
+		attrs = append(attrs, slog.Int("error_code", ctx.errorCode))
+	}
+	if r.options.LogRequests {
+		attrs = append(attrs, slog.Any("params", r.Redact(ctx.Method, rpcRequest.Params)))
+		if ctx.Response != nil {
+			attrs = append(attrs, slog.Any("result", r.Redact(ctx.Method, ctx.Response)))
+		}
+	}
+	ctx.Slog.LogAttrs(context.Background(), level, "request", attrs...)
+}
@@ -0,0 +1,245 @@
+// metrics.go
+package go_jsonrpc
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Histogram bucket upper bounds used by the metrics exporters
+var (
+	latencyBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	sizeBuckets    = []float64{64, 256, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304, 16777216}
+)
+
+// unknownMethodLabel is the method label used for requests to unregistered methods,
+// so clients cannot blow up the label cardinality
+const unknownMethodLabel = "_unknown"
+
+// metrics collects request statistics exposed by MetricsHandler and PublishExpvar
+type metrics struct {
+	mu               sync.Mutex
+	methods          map[string]*methodMetrics
+	activeConns      atomic.Int64
+	inFlight         atomic.Int64
+	busyWorkers      atomic.Int64
+	rejectedConns    atomic.Uint64
+	rejectedRequests atomic.Uint64
+}
+
+// methodMetrics holds the statistics of a single method
+type methodMetrics struct {
+	requests     uint64
+	errors       map[int]uint64 // Error responses by JSON-RPC code
+	latency      *histogram
+	requestSize  *histogram
+	responseSize *histogram
+}
+
+// histogram is a cumulative-bucket histogram in the Prometheus sense
+type histogram struct {
+	bounds []float64
+	counts []uint64 // counts[i] is the number of observations <= bounds[i]; the last entry is +Inf
+	sum    float64
+	count  uint64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]uint64, len(bounds)+1)}
+}
+
+func (h *histogram) observe(v float64) {
+	for i, bound := range h.bounds {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.counts[len(h.bounds)]++
+	h.sum += v
+	h.count++
+}
+
+func newMetrics() *metrics {
+	return &metrics{methods: make(map[string]*methodMetrics)}
+}
+
+// observe records a finished request
+func (m *metrics) observe(method string, errorCode int, elapsed time.Duration, requestSize, responseSize int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mm, ok := m.methods[method]
+	if !ok {
+		mm = &methodMetrics{
+			errors:       make(map[int]uint64),
+			latency:      newHistogram(latencyBuckets),
+			requestSize:  newHistogram(sizeBuckets),
+			responseSize: newHistogram(sizeBuckets),
+		}
+		m.methods[method] = mm
+	}
+	mm.requests++
+	if errorCode != 0 {
+		mm.errors[errorCode]++
+	}
+	mm.latency.observe(elapsed.Seconds())
+	mm.requestSize.observe(float64(requestSize))
+	mm.responseSize.observe(float64(responseSize))
+}
+
+// MetricsHandler returns an http.Handler that serves the server metrics
+// in the Prometheus text exposition format.
+func (r *JsRPC) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.metrics.writePrometheus(w)
+	})
+}
+
+// PublishExpvar exports the server metrics as an expvar variable with the given name.
+// Like expvar.Publish, it panics if the name is already registered.
+func (r *JsRPC) PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(r.metrics.snapshot))
+}
+
+func (m *metrics) writePrometheus(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.methods))
+	for name := range m.methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "# HELP jsonrpc_requests_total Total number of JSON-RPC requests.")
+	fmt.Fprintln(w, "# TYPE jsonrpc_requests_total counter")
+	for _, name := range names {
+		fmt.Fprintf(w, "jsonrpc_requests_total{method=\"%s\"} %d\n", escapeLabel(name), m.methods[name].requests)
+	}
+
+	fmt.Fprintln(w, "# HELP jsonrpc_errors_total Total number of JSON-RPC error responses by code.")
+	fmt.Fprintln(w, "# TYPE jsonrpc_errors_total counter")
+	for _, name := range names {
+		codes := make([]int, 0, len(m.methods[name].errors))
+		for code := range m.methods[name].errors {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+		for _, code := range codes {
+			fmt.Fprintf(w, "jsonrpc_errors_total{method=\"%s\",code=\"%d\"} %d\n", escapeLabel(name), code, m.methods[name].errors[code])
+		}
+	}
+
+	writeHistograms(w, "jsonrpc_request_duration_seconds", "Time spent dispatching JSON-RPC requests.", m.methods, names, func(mm *methodMetrics) *histogram { return mm.latency })
+	writeHistograms(w, "jsonrpc_request_size_bytes", "Size of JSON-RPC requests.", m.methods, names, func(mm *methodMetrics) *histogram { return mm.requestSize })
+	writeHistograms(w, "jsonrpc_response_size_bytes", "Size of JSON-RPC responses.", m.methods, names, func(mm *methodMetrics) *histogram { return mm.responseSize })
+
+	fmt.Fprintln(w, "# HELP jsonrpc_active_connections Number of open server connections.")
+	fmt.Fprintln(w, "# TYPE jsonrpc_active_connections gauge")
+	fmt.Fprintf(w, "jsonrpc_active_connections %d\n", m.activeConns.Load())
+
+	fmt.Fprintln(w, "# HELP jsonrpc_in_flight_requests Number of requests being dispatched.")
+	fmt.Fprintln(w, "# TYPE jsonrpc_in_flight_requests gauge")
+	fmt.Fprintf(w, "jsonrpc_in_flight_requests %d\n", m.inFlight.Load())
+
+	fmt.Fprintln(w, "# HELP jsonrpc_busy_workers Number of requests holding a worker.")
+	fmt.Fprintln(w, "# TYPE jsonrpc_busy_workers gauge")
+	fmt.Fprintf(w, "jsonrpc_busy_workers %d\n", m.busyWorkers.Load())
+
+	fmt.Fprintln(w, "# HELP jsonrpc_rejected_connections_total Connections closed because the connection limit was reached.")
+	fmt.Fprintln(w, "# TYPE jsonrpc_rejected_connections_total counter")
+	fmt.Fprintf(w, "jsonrpc_rejected_connections_total %d\n", m.rejectedConns.Load())
+
+	fmt.Fprintln(w, "# HELP jsonrpc_rejected_requests_total Requests refused because the per-connection limit was reached.")
+	fmt.Fprintln(w, "# TYPE jsonrpc_rejected_requests_total counter")
+	fmt.Fprintf(w, "jsonrpc_rejected_requests_total %d\n", m.rejectedRequests.Load())
+}
+
+func writeHistograms(w io.Writer, metric, help string, methods map[string]*methodMetrics, names []string, get func(*methodMetrics) *histogram) {
+	fmt.Fprintf(w, "# HELP %s %s\n", metric, help)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", metric)
+	for _, name := range names {
+		h := get(methods[name])
+		label := escapeLabel(name)
+		for i, bound := range h.bounds {
+			fmt.Fprintf(w, "%s_bucket{method=\"%s\",le=\"%s\"} %d\n", metric, label, strconv.FormatFloat(bound, 'g', -1, 64), h.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{method=\"%s\",le=\"+Inf\"} %d\n", metric, label, h.counts[len(h.bounds)])
+		fmt.Fprintf(w, "%s_sum{method=\"%s\"} %s\n", metric, label, strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(w, "%s_count{method=\"%s\"} %d\n", metric, label, h.count)
+	}
+}
+
+// snapshot returns the metrics as plain values for expvar
+func (m *metrics) snapshot() any {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	methods := make(map[string]any, len(m.methods))
+	for name, mm := range m.methods {
+		errs := make(map[string]uint64, len(mm.errors))
+		for code, n := range mm.errors {
+			errs[strconv.Itoa(code)] = n
+		}
+		methods[name] = map[string]any{
+			"requests":            mm.requests,
+			"errors":              errs,
+			"latency_seconds_sum": mm.latency.sum,
+			"request_bytes_sum":   mm.requestSize.sum,
+			"response_bytes_sum":  mm.responseSize.sum,
+		}
+	}
+	return map[string]any{
+		"methods":              methods,
+		"active_connections":   m.activeConns.Load(),
+		"in_flight_requests":   m.inFlight.Load(),
+		"busy_workers":         m.busyWorkers.Load(),
+		"rejected_connections": m.rejectedConns.Load(),
+		"rejected_requests":    m.rejectedRequests.Load(),
+	}
+}
+
+// escapeLabel escapes a Prometheus label value
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	reader io.Reader
+	n      int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	writer io.Writer
+	n      int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.writer.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Flush flushes the underlying writer when it supports it, for streamed responses
+func (c *countingWriter) Flush() {
+	if f, ok := c.writer.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -4,16 +4,51 @@ package go_jsonrpc
 import (
 	"io"
 	"log"
+	"log/slog"
+	"net"
 	"os"
+	"time"
 )
 
 // Options defines configuration options for JsRPC
 type Options struct {
-	CGI                bool   // Flag to control CGI header output
-	Logger             Logger // Logger for logging events
-	LogRequests        bool   // Flag to log requests
-	HandlerInterceptor func(reader io.Reader, writer io.Writer) (finished bool, err error)
-	SocketPerms        os.FileMode // File permissions for Unix socket when used. 0 means no change.
+	CGI                  bool           // Write CGI headers (a Status line and Content-Type) when executing commands with ExecuteCommand
+	CGIMaxBodyBytes      int64          // Request body limit of ServeCGI and ServeFastCGI. 0 means DefaultCGIMaxBodyBytes
+	Logger               Logger         // Logger for logging events
+	SlogLogger           *slog.Logger   // Structured logger. When set it takes precedence over Logger
+	LogLevel             slog.Level     // Minimum level logged through Logger when SlogLogger is not set (default: info)
+	LogRequests          bool           // Flag to log requests at info level, including their params and result
+	Redact               *RedactOptions // Redaction rules applied to params and results before they are logged or recorded
+	Tracer               Tracer         // Tracer starting a span per request, with child spans for middlewares and the handler
+	HandlerInterceptor   func(reader io.Reader, writer io.Writer) (finished bool, err error)
+	SocketPerms          os.FileMode                                 // File permissions for Unix socket when used. 0 means no change.
+	MaxConnections       int                                         // Connections served at once by a listener. 0 means no limit
+	MaxConnectionsReject bool                                        // Close connections over MaxConnections instead of waiting to accept them
+	MaxInFlightPerConn   int                                         // Requests executed at once per persistent connection; more are answered with ServerBusy. 0 means no limit
+	MaxWorkers           int                                         // Requests executed at once by the server, on all transports; more wait for a worker. 0 means no limit
+	MaxMessageBytes      int64                                       // Size limit of a request, checked while it is read. 0 means no limit, except DefaultMaxFrameBytes on framed streams
+	MaxDepth             int                                         // Nesting limit of objects and arrays in a request. 0 means no limit
+	MaxParamsKeys        int                                         // Number of keys, or elements, allowed in the params of a request. 0 means no limit
+	FirstByteTimeout     time.Duration                               // Time for an accepted connection to send its first byte. 0 means no limit (IdleTimeout on persistent connections)
+	ReadTimeout          time.Duration                               // Time to read a whole request once its first byte arrived. 0 means no limit
+	WriteTimeout         time.Duration                               // Time to complete each write on an accepted connection. 0 means no limit
+	IdleTimeout          time.Duration                               // Time a persistent connection without running requests or subscriptions may wait for its next request. 0 means no limit
+	OnConnect            func(conn net.Conn) error                   // Called for each connection accepted by a listener. Returning an error rejects (closes) it
+	OnDisconnect         func(conn net.Conn, duration time.Duration) // Called once an accepted connection is closed, with how long it was open
+	OnAcceptError        func(err error)                             // Called when a listener fails to accept a connection
+	OnRequestError       func(ctx *Context, err error)               // Called when a request cannot be parsed, or its handler returns an error or panics
+	Codec                Codec                                       // Message framing for listeners and ServeStream. nil keeps one request per connection on listeners
+	CancelMethod         string                                      // Notification cancelling an in-flight request on persistent connections. Defaults to DefaultCancelMethod
+	ProgressMethod       string                                      // Notification carrying ctx.Progress() updates. Defaults to DefaultProgressMethod
+	StreamChunkMethod    string                                      // Notification carrying the items of ctx.StreamArray on persistent connections. Defaults to DefaultStreamChunkMethod
+	StreamChunkSize      int                                         // Items per chunk notification, and per flush on HTTP, for ctx.StreamArray. 0 means DefaultStreamChunkSize
+	JobStore             JobStore                                    // Store of RegisterAsyncCommand jobs. Defaults to an in-memory store with DefaultJobTTL
+	JobWorkers           int                                         // Workers running asynchronous jobs. 0 means DefaultJobWorkers
+	JobQueueSize         int                                         // Jobs waiting for a worker before submissions are rejected. 0 means DefaultJobQueueSize
+	StdioContentLength   bool                                        // Frame ServeStdio/ServeStream messages with LSP-style Content-Length headers instead of newlines
+	HTTPAllowGet         bool                                        // Accept GET requests with query-encoded method, params and id in ServeHTTP
+	CORS                 *CORSOptions                                // CORS policy applied by ServeHTTP. nil disables CORS headers
+	HTTPStatusFunc       func(code int) int                          // Maps JSON-RPC error codes (0 on success) to HTTP statuses. Defaults to HTTPStatusForCode
 }
 
 // DefaultOptions provides default configuration for JsRPC
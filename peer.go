@@ -0,0 +1,284 @@
+// peer.go
+package go_jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrPeerClosed is returned when sending to a connection that has been closed
+var ErrPeerClosed = errors.New("peer connection closed")
+
+// Peer is a persistent connection (socket with a codec, stdio or WebSocket) on which
+// the server can send messages at any time, not only in response to a request.
+// Both sides of the connection can serve requests and issue calls: see Call and NewPeer.
+type Peer struct {
+	id          string
+	remoteAddr  string
+	connectedAt time.Time
+	framer      Framer
+	writeMu     sync.Mutex   // Serializes the frames written on the connection
+	closeFn     func() error // Closes the underlying connection, may be nil
+	closeOnce   sync.Once
+	done        chan struct{}
+	session     *Session
+	subs        map[string]*Sink // Active subscriptions by ID
+	subsMu      sync.Mutex
+	server      *JsRPC // Registry serving incoming requests, set by NewPeer
+	nextID      atomic.Uint64
+	pending     map[string]chan *peerResponse // Outgoing calls waiting for a response, by request ID
+	pendingMu   sync.Mutex
+	inflight    map[string]context.CancelCauseFunc // Incoming requests being served, by request ID
+	inflightMu  sync.Mutex
+	onActivity  func(delta int) // Set by the server: counts requests, outgoing calls and subscriptions
+}
+
+// peerResponse is a response to an outgoing call, read on the connection
+type peerResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *JSONRPCError   `json:"error"`
+}
+
+// NewPeer creates a peer on an established connection, typically on the client side of a socket.
+// Incoming requests are served by r, which must not be nil, and the other side can be called with Call.
+// Messages are framed with codec. Serve must be running for responses to be received.
+func NewPeer(r *JsRPC, rwc io.ReadWriteCloser, codec Codec) *Peer {
+	remoteAddr := ""
+	if conn, ok := rwc.(net.Conn); ok {
+		remoteAddr = conn.RemoteAddr().String()
+	}
+	p := newPeer(codec.NewFramer(rwc), remoteAddr, rwc.Close)
+	p.server = r
+	return p
+}
+
+// Serve reads messages from a peer created with NewPeer, dispatching requests to its registry and
+// delivering responses to pending calls. It blocks until the connection is closed, then closes it.
+func (p *Peer) Serve() error {
+	defer p.closeFn()
+	err := p.server.servePeer(p, &transport{remoteAddr: p.remoteAddr})
+	if errors.Is(err, net.ErrClosed) {
+		return nil // Closed with Close
+	}
+	return err
+}
+
+func newPeer(f Framer, remoteAddr string, closeFn func() error) *Peer {
+	return &Peer{
+		id:          newPeerID(),
+		remoteAddr:  remoteAddr,
+		connectedAt: time.Now(),
+		framer:      f,
+		closeFn:     closeFn,
+		done:        make(chan struct{}),
+		session:     newSession(),
+	}
+}
+
+// ID returns the unique identifier of the connection
+func (p *Peer) ID() string {
+	return p.id
+}
+
+// RemoteAddr returns the remote address of the connection, if known
+func (p *Peer) RemoteAddr() string {
+	return p.remoteAddr
+}
+
+// ConnectedAt returns when the connection was established
+func (p *Peer) ConnectedAt() time.Time {
+	return p.connectedAt
+}
+
+// Done returns a channel closed when the connection is closed
+func (p *Peer) Done() <-chan struct{} {
+	return p.done
+}
+
+// Notify sends a JSON-RPC notification (a request without ID) to the client
+func (p *Peer) Notify(method string, params any) error {
+	msg, err := json.Marshal(JSONRPCRequest{JSONRPC: "2.0", Method: method, Params: params})
+	if err != nil {
+		return fmt.Errorf("marshal notification: %w", err)
+	}
+	return p.send(msg)
+}
+
+// Close closes the connection
+func (p *Peer) Close() error {
+	var err error
+	p.closeOnce.Do(func() {
+		close(p.done)
+		if p.closeFn != nil {
+			err = p.closeFn()
+		}
+	})
+	p.closeSubscriptions()
+	return err
+}
+
+// Call sends a request to the other side of the connection and waits for the response, decoding its
+// result into result, which may be nil. Error responses are returned as *JSONRPCError.
+// The call fails with ErrPeerClosed if the connection closes first, or with the error of ctx,
+// in which case the other side is sent a cancel notification.
+func (p *Peer) Call(ctx context.Context, method string, params any, result any) error {
+	id := p.nextID.Add(1)
+	key := strconv.FormatUint(id, 10)
+	ch := make(chan *peerResponse, 1)
+
+	p.pendingMu.Lock()
+	if p.pending == nil {
+		p.pending = make(map[string]chan *peerResponse)
+	}
+	p.pending[key] = ch
+	p.pendingMu.Unlock()
+	p.activity(1)
+	defer func() {
+		p.pendingMu.Lock()
+		delete(p.pending, key)
+		p.pendingMu.Unlock()
+		p.activity(-1)
+	}()
+
+	msg, err := json.Marshal(JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  params,
+		ID:      id,
+		Meta:    InjectTraceMeta(ctx),
+	})
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
+	}
+	if err := p.send(msg); err != nil {
+		return err
+	}
+
+	select {
+	case res := <-ch:
+		if res.Error != nil {
+			return res.Error
+		}
+		if result != nil && len(res.Result) > 0 {
+			if err := json.Unmarshal(res.Result, result); err != nil {
+				return fmt.Errorf("unmarshal result: %w", err)
+			}
+		}
+		return nil
+	case <-ctx.Done():
+		_ = p.sendCancel(id)
+		return ctx.Err()
+	case <-p.done:
+		return ErrPeerClosed
+	}
+}
+
+// activity reports a request, outgoing call or subscription starting (1) or ending (-1).
+// The connection is idle when none are in progress.
+func (p *Peer) activity(delta int) {
+	if p.onActivity != nil {
+		p.onActivity(delta)
+	}
+}
+
+// deliver hands a response to the outgoing call waiting for it.
+// It reports false when no call is waiting for that ID.
+func (p *Peer) deliver(res *peerResponse) bool {
+	key := string(bytes.TrimSpace(res.ID))
+	p.pendingMu.Lock()
+	ch, ok := p.pending[key]
+	delete(p.pending, key)
+	p.pendingMu.Unlock()
+	if ok {
+		ch <- res
+	}
+	return ok
+}
+
+// send writes a whole message on the connection
+func (p *Peer) send(msg []byte) error {
+	select {
+	case <-p.done:
+		return ErrPeerClosed
+	default:
+	}
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+	return p.framer.WriteMessage(msg)
+}
+
+// Peer returns the persistent connection the request arrived on,
+// or nil for single-request transports (HTTP, CGI, one-shot sockets).
+func (ctx *Context) Peer() *Peer {
+	if ctx.transport == nil {
+		return nil
+	}
+	return ctx.transport.peer
+}
+
+// Peer returns the connected peer with the given ID, or nil
+func (r *JsRPC) Peer(id string) *Peer {
+	r.peersMu.RLock()
+	defer r.peersMu.RUnlock()
+	return r.peers[id]
+}
+
+// Peers returns the currently connected peers
+func (r *JsRPC) Peers() []*Peer {
+	r.peersMu.RLock()
+	defer r.peersMu.RUnlock()
+	peers := make([]*Peer, 0, len(r.peers))
+	for _, p := range r.peers {
+		peers = append(peers, p)
+	}
+	return peers
+}
+
+// Broadcast sends a notification to every connected peer.
+// It returns the errors of the peers the notification could not be sent to.
+func (r *JsRPC) Broadcast(method string, params any) error {
+	msg, err := json.Marshal(JSONRPCRequest{JSONRPC: "2.0", Method: method, Params: params})
+	if err != nil {
+		return fmt.Errorf("marshal notification: %w", err)
+	}
+	var errs []error
+	for _, p := range r.Peers() {
+		if err := p.send(msg); err != nil {
+			errs = append(errs, fmt.Errorf("peer %s: %w", p.id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r *JsRPC) addPeer(p *Peer) {
+	r.peersMu.Lock()
+	defer r.peersMu.Unlock()
+	if r.peers == nil {
+		r.peers = make(map[string]*Peer)
+	}
+	r.peers[p.id] = p
+}
+
+func (r *JsRPC) removePeer(p *Peer) {
+	r.peersMu.Lock()
+	defer r.peersMu.Unlock()
+	delete(r.peers, p.id)
+}
+
+func newPeerID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,169 @@
+// persistent.go
+package go_jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+)
+
+// serveStream reads a continuous stream of messages and dispatches each of them concurrently.
+// Responses are written back as whole frames, in completion order. It returns when the stream ends.
+// The connection is registered as a Peer, closed with closeFn, for the lifetime of the stream.
+func (r *JsRPC) serveStream(f Framer, t *transport, closeFn func() error) error {
+	return r.servePeer(newPeer(f, t.remoteAddr, closeFn), t)
+}
+
+// servePeer reads the messages of a peer until the stream ends. Requests and notifications are
+// dispatched concurrently; responses to the peer's outgoing calls are delivered to the waiting callers.
+func (r *JsRPC) servePeer(peer *Peer, t *transport) error {
+	f := peer.framer
+	peer.server = r
+	peer.onActivity = t.activity
+	if limiter, ok := f.(messageLimiter); ok && r.options.MaxMessageBytes > 0 {
+		limiter.setMaxMessageBytes(r.options.MaxMessageBytes)
+	}
+	var inflight chan struct{} // Slots of the requests being executed, when limited
+	if r.options.MaxInFlightPerConn > 0 {
+		inflight = make(chan struct{}, r.options.MaxInFlightPerConn)
+	}
+	r.addPeer(peer)
+	var wg sync.WaitGroup
+	defer func() {
+		peer.cancelRequests(ErrPeerClosed)
+		wg.Wait()
+		r.removePeer(peer)
+		peer.closeOnce.Do(func() { close(peer.done) }) // The caller closes the connection itself
+		peer.closeSubscriptions()
+		peer.session.close()
+	}()
+
+	for {
+		if t.awaitMessage != nil {
+			t.awaitMessage()
+		}
+		msg, err := f.ReadMessage()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if errors.Is(err, ErrMessageTooLarge) {
+				// The stream cannot be resynchronized: answer, then close the connection
+				r.slog.Warn("request rejected", slog.String("remote", t.remoteAddr), slog.String("reason", err.Error()))
+				res, _ := json.Marshal(JSONRPCResponse{JSONRPC: "2.0", Error: &JSONRPCError{Code: RequestTooLarge, Message: fmt.Sprintf("request exceeds %d bytes", frameLimit(r.options.MaxMessageBytes))}})
+				_ = peer.send(res)
+			}
+			return err
+		}
+
+		var probe struct {
+			peerResponse
+			Method *string         `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		if json.Unmarshal(msg, &probe) == nil {
+			// Responses to outgoing calls carry an ID but no method
+			if probe.Method == nil && len(probe.ID) > 0 {
+				if !peer.deliver(&probe.peerResponse) {
+					r.slog.Debug("dropped response to unknown call", slog.String("remote", t.remoteAddr), slog.String("id", string(probe.ID)))
+				}
+				continue
+			}
+			// Cancel notifications are handled as soon as they are read
+			if probe.Method != nil && *probe.Method == r.cancelMethod() && len(probe.ID) == 0 {
+				var params cancelParams
+				if json.Unmarshal(probe.Params, &params) != nil || !peer.cancelRequest(params.ID) {
+					r.slog.Debug("cancel for unknown request", slog.String("remote", t.remoteAddr), slog.String("params", string(probe.Params)))
+				}
+				continue
+			}
+		}
+
+		if inflight != nil {
+			select {
+			case inflight <- struct{}{}:
+			default:
+				r.metrics.rejectedRequests.Add(1)
+				r.rejectBusy(peer, msg)
+				continue
+			}
+		}
+
+		// Let the client cancel the request from now on, even while it waits for a worker
+		rt := *t
+		untrack := func() {}
+		if id := probeRequestID(probe.ID); probe.Method != nil && id != nil {
+			if rt.ctx == nil {
+				rt.ctx = context.Background()
+			}
+			rt.ctx, untrack = peer.trackRequest(rt.ctx, id)
+		}
+
+		peer.activity(1)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer peer.activity(-1)
+			defer untrack()
+			if inflight != nil {
+				defer func() { <-inflight }()
+			}
+			res := &streamResponse{peer: peer}
+			rt.stream = true
+			rt.peer = peer
+			rt.beforeResponse = res.beforeResponse
+			if err := r.executeCommandWithData(bytes.NewReader(msg), res, nil, &rt); err != nil {
+				r.slog.Error("error processing request", slog.String("remote", t.remoteAddr), slog.Any("error", err))
+			}
+		}()
+	}
+}
+
+// probeRequestID decodes the ID of a request read from a peer, nil for notifications
+func probeRequestID(raw json.RawMessage) any {
+	var id any
+	if len(raw) == 0 || json.Unmarshal(raw, &id) != nil {
+		return nil
+	}
+	return id
+}
+
+// streamResponse writes each Write call as one frame on a persistent stream
+type streamResponse struct {
+	peer    *Peer
+	discard bool // Drop the response, used for notifications
+}
+
+// beforeResponse drops responses to notifications, which must not be answered
+func (s *streamResponse) beforeResponse(ctx *Context, _ *JSONRPCResponse) {
+	s.discard = ctx.notification
+}
+
+func (s *streamResponse) Write(p []byte) (int, error) {
+	if s.discard {
+		return len(p), nil
+	}
+	if err := s.peer.send(bytes.TrimRight(p, "\r\n")); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// rejectBusy answers a request over the per-connection in-flight limit with a ServerBusy error
+func (r *JsRPC) rejectBusy(peer *Peer, msg []byte) {
+	var req struct {
+		ID any `json:"id"`
+	}
+	if json.Unmarshal(msg, &req) != nil || req.ID == nil {
+		return // Notifications are dropped
+	}
+	res, err := json.Marshal(JSONRPCResponse{JSONRPC: "2.0", Error: &JSONRPCError{Code: ServerBusy, Message: "too many requests in flight"}, ID: req.ID})
+	if err == nil {
+		_ = peer.send(res)
+	}
+}
@@ -0,0 +1,84 @@
+// progress.go
+package go_jsonrpc
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// DefaultProgressMethod is the notification carrying progress updates, as in the Language Server Protocol
+const DefaultProgressMethod = "$/progress"
+
+// ProgressTokenMeta is the key of the request "meta" object holding the client-supplied progress token
+const ProgressTokenMeta = "progressToken"
+
+// ProgressValue is a progress update. Percentage and Partial are omitted when nil.
+type ProgressValue struct {
+	Percentage *float64 `json:"percentage,omitempty"` // Completion, from 0 to 100
+	Message    string   `json:"message,omitempty"`    // Human-readable status
+	Partial    any      `json:"partial,omitempty"`    // Partial results
+}
+
+// progressParams are the params of a progress notification
+type progressParams struct {
+	Token any           `json:"token"`
+	Value ProgressValue `json:"value"`
+}
+
+// ProgressReporter sends progress notifications for a request.
+// Reports are dropped unless the request arrived on a persistent connection with a progress token,
+// so handlers can report progress unconditionally.
+type ProgressReporter struct {
+	ctx   *Context
+	token any
+}
+
+// Progress returns the progress reporter of the request. The client enables progress by sending
+// a token in the request meta, e.g. {"meta": {"progressToken": "import-1"}}; updates are then sent
+// as notifications with params {"token": token, "value": {...}}, before the final response.
+func (ctx *Context) Progress() *ProgressReporter {
+	var token any
+	if ctx.meta != nil {
+		token = ctx.meta[ProgressTokenMeta]
+	}
+	return &ProgressReporter{ctx: ctx, token: token}
+}
+
+// Enabled reports whether progress updates reach the client
+func (p *ProgressReporter) Enabled() bool {
+	return p.token != nil && p.ctx.Peer() != nil
+}
+
+// Report sends the completion percentage (0 to 100) and a status message
+func (p *ProgressReporter) Report(percentage float64, message string) error {
+	return p.Send(ProgressValue{Percentage: &percentage, Message: message})
+}
+
+// Partial sends partial results
+func (p *ProgressReporter) Partial(result any) error {
+	return p.Send(ProgressValue{Partial: result})
+}
+
+// Send sends a progress update. It does nothing when progress is not enabled or the request has been answered.
+func (p *ProgressReporter) Send(value ProgressValue) error {
+	if !p.Enabled() || p.ctx.responded {
+		return nil
+	}
+	msg, err := json.Marshal(JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  p.ctx.server.progressMethod(),
+		Params:  progressParams{Token: p.token, Value: value},
+	})
+	if err != nil {
+		return fmt.Errorf("marshal progress: %w", err)
+	}
+	return p.ctx.Peer().send(msg)
+}
+
+// progressMethod returns the name of the progress notification
+func (r *JsRPC) progressMethod() string {
+	if r == nil || r.options.ProgressMethod == "" {
+		return DefaultProgressMethod
+	}
+	return r.options.ProgressMethod
+}
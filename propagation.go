@@ -0,0 +1,129 @@
+// propagation.go
+package go_jsonrpc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// W3C trace context header names, also used as keys in the request "meta" object
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+// ParseTraceparent parses a W3C traceparent value ("00-<trace-id>-<span-id>-<flags>")
+func ParseTraceparent(value string) (SpanContext, error) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, errors.New("malformed traceparent")
+	}
+	if parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return sc, errors.New("unsupported traceparent version")
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, errors.New("malformed traceparent trace-id")
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, errors.New("malformed traceparent parent-id")
+	}
+	var flags [1]byte
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return sc, errors.New("malformed traceparent flags")
+	}
+	sc.Flags = flags[0]
+	if !sc.IsValid() {
+		return sc, errors.New("traceparent with zero trace-id or parent-id")
+	}
+	return sc, nil
+}
+
+// FormatTraceparent formats a span context as a W3C traceparent value
+func FormatTraceparent(sc SpanContext) string {
+	return "00-" + sc.TraceIDString() + "-" + sc.SpanIDString() + "-" + hex.EncodeToString([]byte{sc.Flags})
+}
+
+// NewSpanContext returns a sampled span context with random trace and span IDs
+func NewSpanContext() SpanContext {
+	sc := SpanContext{Flags: 0x01}
+	_, _ = rand.Read(sc.TraceID[:])
+	_, _ = rand.Read(sc.SpanID[:])
+	return sc
+}
+
+type remoteSpanContextKey struct{}
+
+// ContextWithRemoteSpanContext returns a copy of ctx carrying a span context received from a caller.
+// Spans started from the returned context join the caller's trace.
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteSpanContextKey{}, sc)
+}
+
+// SpanContextFromContext returns the span context of the current span in ctx,
+// falling back to a remote span context. The result is invalid when there is neither.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if span := SpanFromContext(ctx); span != nil {
+		if sc := span.SpanContext(); sc.IsValid() {
+			return sc
+		}
+	}
+	sc, _ := ctx.Value(remoteSpanContextKey{}).(SpanContext)
+	return sc
+}
+
+// ExtractTraceHeaders reads the traceparent and tracestate headers into ctx.
+// ctx is returned unchanged when the headers are missing or malformed.
+func ExtractTraceHeaders(ctx context.Context, header http.Header) context.Context {
+	return extractTraceContext(ctx, header.Get(TraceparentHeader), header.Get(TracestateHeader))
+}
+
+// InjectTraceHeaders writes the span context found in ctx as traceparent and tracestate headers
+func InjectTraceHeaders(ctx context.Context, header http.Header) {
+	sc := SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return
+	}
+	header.Set(TraceparentHeader, FormatTraceparent(sc))
+	if sc.TraceState != "" {
+		header.Set(TracestateHeader, sc.TraceState)
+	}
+}
+
+// extractMetaTraceContext reads the trace context from the request "meta" object,
+// used by transports without headers.
+func extractMetaTraceContext(ctx context.Context, meta map[string]any) context.Context {
+	traceparent, _ := meta[TraceparentHeader].(string)
+	tracestate, _ := meta[TracestateHeader].(string)
+	return extractTraceContext(ctx, traceparent, tracestate)
+}
+
+// InjectTraceMeta returns the span context found in ctx as a request "meta" object, or nil.
+// Clients send it with their requests over transports without headers, such as TCP.
+func InjectTraceMeta(ctx context.Context) map[string]any {
+	sc := SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return nil
+	}
+	meta := map[string]any{TraceparentHeader: FormatTraceparent(sc)}
+	if sc.TraceState != "" {
+		meta[TracestateHeader] = sc.TraceState
+	}
+	return meta
+}
+
+func extractTraceContext(ctx context.Context, traceparent, tracestate string) context.Context {
+	if traceparent == "" {
+		return ctx
+	}
+	sc, err := ParseTraceparent(traceparent)
+	if err != nil {
+		return ctx
+	}
+	sc.TraceState = tracestate
+	return ContextWithRemoteSpanContext(ctx, sc)
+}
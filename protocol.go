@@ -1,12 +1,15 @@
 // protocol.go
 package go_jsonrpc
 
+import "fmt"
+
 // JSONRPCRequest represents a standard JSON-RPC 2.0 request
 type JSONRPCRequest struct {
-	JSONRPC string      `json:"jsonrpc"`
-	Method  string      `json:"method"`
-	Params  any         `json:"params"`
-	ID      interface{} `json:"id,omitempty"` // Can be a string, number or null
+	JSONRPC string         `json:"jsonrpc"`
+	Method  string         `json:"method"`
+	Params  any            `json:"params"`
+	ID      interface{}    `json:"id,omitempty"`   // Can be a string, number or null
+	Meta    map[string]any `json:"meta,omitempty"` // Out-of-band request metadata (e.g. traceparent on transports without headers)
 }
 
 // JSONRPCResponse represents a standard JSON-RPC 2.0 response
@@ -24,6 +27,11 @@ type JSONRPCError struct {
 	Data    interface{} `json:"data,omitempty"`
 }
 
+// Error implements the error interface, so that error responses to outgoing calls can be returned as errors
+func (e *JSONRPCError) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
 // Error codes according to JSON-RPC 2.0
 const (
 	ParseError       = -32700
@@ -32,4 +40,9 @@ const (
 	InvalidParams    = -32602
 	InternalError    = -32603
 	InterceptorError = 32000
+	RequestCancelled = -32800 // The client cancelled the request (Language Server Protocol)
+	JobNotFinished   = -32001 // job.result was called before the job finished
+	JobNotFound      = -32002 // Unknown or expired job
+	ServerBusy       = -32003 // A concurrency limit was reached
+	RequestTooLarge  = -32004 // The request exceeds a size or complexity limit
 )
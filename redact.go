@@ -0,0 +1,116 @@
+// redact.go
+package go_jsonrpc
+
+import (
+	"encoding/json"
+	"path"
+	"strings"
+)
+
+// DefaultRedactMarker replaces redacted values when RedactOptions.Marker is empty
+const DefaultRedactMarker = "[REDACTED]"
+
+// RedactOptions defines which values are masked before params and results are logged or recorded
+type RedactOptions struct {
+	// Fields lists, per method, the fields to redact as dot-separated paths
+	// (e.g. "login": {"password", "credentials.token"}). Arrays are traversed transparently.
+	Fields map[string][]string
+	// Patterns lists key patterns redacted at any depth in every method.
+	// They are matched case-insensitively with path.Match syntax (e.g. "*token*", "password").
+	Patterns []string
+	// Marker replaces redacted values. DefaultRedactMarker is used when empty.
+	Marker string
+}
+
+// Redact returns a copy of v with the values matched by the redaction rules replaced by the marker.
+// v is converted through encoding/json first, so structs at any depth are redacted by their JSON keys.
+// v is returned unchanged when no rules are configured.
+func (r *JsRPC) Redact(method string, v any) any {
+	return r.options.Redact.redact(method, v)
+}
+
+func (o *RedactOptions) redact(method string, v any) any {
+	if o == nil || v == nil || (len(o.Patterns) == 0 && len(o.Fields[method]) == 0) {
+		return v
+	}
+
+	var paths [][]string
+	for _, field := range o.Fields[method] {
+		paths = append(paths, strings.Split(field, "."))
+	}
+
+	marker := o.Marker
+	if marker == "" {
+		marker = DefaultRedactMarker
+	}
+
+	normalized, ok := normalizeJSON(v)
+	if !ok {
+		return marker // Nothing is logged from a value that cannot be inspected
+	}
+	return o.redactValue(normalized, paths, marker)
+}
+
+func (o *RedactOptions) redactValue(v any, paths [][]string, marker string) any {
+	switch val := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(val))
+		for key, item := range val {
+			var childPaths [][]string
+			redacted := o.matchesPattern(key)
+			for _, p := range paths {
+				if p[0] != key {
+					continue
+				}
+				if len(p) == 1 {
+					redacted = true
+					break
+				}
+				childPaths = append(childPaths, p[1:])
+			}
+			if redacted {
+				out[key] = marker
+				continue
+			}
+			out[key] = o.redactValue(item, childPaths, marker)
+		}
+		return out
+	case []any:
+		out := make([]any, len(val))
+		for i, item := range val {
+			out[i] = o.redactValue(item, paths, marker)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// matchesPattern reports whether key matches any of the global key patterns
+func (o *RedactOptions) matchesPattern(key string) bool {
+	key = strings.ToLower(key)
+	for _, pattern := range o.Patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeJSON converts v into the generic form produced by encoding/json (maps, slices and scalars).
+// Maps and slices are converted too, since they may hold structs. It reports false if v cannot be encoded.
+func normalizeJSON(v any) (any, bool) {
+	switch v.(type) {
+	case string, float64, bool:
+		return v, true
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, false
+	}
+	var out any
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, false
+	}
+	return out, true
+}
@@ -0,0 +1,46 @@
+package go_jsonrpc
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRedactNestedValues(t *testing.T) {
+	type user struct {
+		Name     string `json:"name"`
+		Password string `json:"password"`
+	}
+	opts := &RedactOptions{Patterns: []string{"password"}, Fields: map[string][]string{"login": {"credentials.token"}}}
+
+	tests := map[string]any{
+		"struct":          user{Name: "ann", Password: "hunter2"},
+		"map of struct":   map[string]any{"user": user{Name: "ann", Password: "hunter2"}},
+		"slice of struct": []any{user{Name: "ann", Password: "hunter2"}},
+		"pointer":         &user{Name: "ann", Password: "hunter2"},
+		"raw params":      json.RawMessage(`{"credentials":{"token":"hunter2"}}`),
+		"field in map":    map[string]any{"credentials": map[string]string{"token": "hunter2"}},
+		"struct in struct": struct {
+			Credentials any `json:"credentials"`
+		}{Credentials: map[string]any{"token": "hunter2"}},
+	}
+	for name, v := range tests {
+		t.Run(name, func(t *testing.T) {
+			b, err := json.Marshal(opts.redact("login", v))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(b), "hunter2") {
+				t.Fatalf("secret logged: %s", b)
+			}
+		})
+	}
+}
+
+func TestRedactUnencodable(t *testing.T) {
+	opts := &RedactOptions{Patterns: []string{"password"}}
+	got := opts.redact("login", map[string]any{"password": "hunter2", "ch": make(chan int)})
+	if got != DefaultRedactMarker {
+		t.Fatalf("got %v, want the marker", got)
+	}
+}
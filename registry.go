@@ -1,7 +1,12 @@
 // registry.go
 package go_jsonrpc
 
-import "os"
+import (
+	"log"
+	"log/slog"
+	"os"
+	"sync"
+)
 
 // command represents a registered command with its handler and specific middlewares.
 type command struct {
@@ -12,11 +17,20 @@ type command struct {
 // JsRPC is the main structure of the JSON-RPC server, handling registered commands and global middlewares.
 type JsRPC struct {
 	handlers    map[string]command
-	middlewares []MiddlewareFunc // Global middlewares
-	cgi         bool             // Flag to write CGI headers
-	logger      Logger           // Logger for logging critical events
+	middlewares []MiddlewareFunc  // Global middlewares
+	cgi         bool              // Flag to write CGI headers
+	logger      Logger            // Logger for logging critical events
+	slog        *slog.Logger      // Structured logger used for all library events
+	logOutput   *defaultLogOutput // Output of the default logger, nil when a logger was provided
 	options     *Options
 	socketPerms os.FileMode
+	metrics     *metrics         // Request statistics
+	limits      *limits          // Concurrency limits
+	tracer      Tracer           // Tracer for request spans, never nil
+	peers       map[string]*Peer // Connected persistent peers by ID
+	peersMu     sync.RWMutex
+	jobs        *jobs // Runner of asynchronous commands, started on first use
+	jobsOnce    sync.Once
 }
 
 // HandlerFunc is the type definition for the function signature of a command handler.
@@ -27,18 +41,48 @@ type MiddlewareFunc func(ctx *Context) error
 
 // New creates a new instance of JsRPC with the given options.
 func New(options *Options) *JsRPC {
+	defaultLogger := options == nil || options.Logger == nil
 	if options == nil {
 		options = DefaultOptions()
 	}
 	if options.Logger == nil {
-		options.Logger = DefaultOptions().Logger
+		if options.SlogLogger != nil {
+			defaultLogger = false
+			options.Logger = slogPrinter{logger: options.SlogLogger}
+		} else {
+			options.Logger = DefaultOptions().Logger
+		}
 	}
-	return &JsRPC{
+	tracer := options.Tracer
+	if tracer == nil {
+		tracer = noopTracer{}
+	}
+	r := &JsRPC{
 		handlers:    make(map[string]command),
 		cgi:         options.CGI,
-		logger:      options.Logger,
 		options:     options,
 		socketPerms: options.SocketPerms,
+		metrics:     newMetrics(),
+		limits:      newLimits(options),
+		tracer:      tracer,
+	}
+	if defaultLogger {
+		r.logOutput = &defaultLogOutput{}
+		options.Logger = log.New(r.logOutput, "JsRPC: ", log.LstdFlags)
+	}
+	r.setLogger(options.Logger)
+	if options.CORS.wildcardWithCredentials() {
+		r.slog.Warn(`CORS: the "*" origin is ignored with AllowCredentials, list the allowed origins or set AllowOriginFunc`)
+	}
+	return r
+}
+
+// setLogger sets the Printf-style logger, wrapping it for structured logging unless a *slog.Logger was provided
+func (r *JsRPC) setLogger(logger Logger) {
+	r.logger = logger
+	r.slog = r.options.SlogLogger
+	if r.slog == nil {
+		r.slog = newPrintfSlogger(logger, r.options.LogLevel)
 	}
 }
 
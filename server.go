@@ -2,14 +2,20 @@
 package go_jsonrpc
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net"
+	"net/http"
 	"os"
+	"runtime/debug"
+	"time"
 )
 
+// Logger is the Printf-style logger accepted in Options.
+// It is wrapped by an adapter so that all library events go through log/slog.
 type Logger interface {
 	Printf(format string, v ...interface{})
 	Println(v ...interface{})
@@ -17,69 +23,231 @@ type Logger interface {
 
 // StartServer listens on a TCP port or Unix socket and executes JSON-RPC commands
 func (r *JsRPC) StartServer(address string, useUnixSocket bool) error {
+	listener, err := r.listen(address, useUnixSocket)
+	if err != nil {
+		return err
+	}
+
+	return r.StartWithListener(listener)
+}
+
+// listen opens a TCP or Unix socket listener, applying the socket permissions
+func (r *JsRPC) listen(address string, useUnixSocket bool) (net.Listener, error) {
 	var listener net.Listener
 	var err error
 
 	// Select TCP or Unix socket based on the configuration
 	if useUnixSocket {
 		listener, err = net.Listen("unix", address)
-		if r.socketPerms != 0 {
+		if err == nil && r.socketPerms != 0 {
 			if err := os.Chmod(address, r.socketPerms); err != nil {
-				return fmt.Errorf("failed to set permissions on unix socket %s: %v", address, err)
+				listener.Close()
+				return nil, fmt.Errorf("failed to set permissions on unix socket %s: %v", address, err)
 			}
 		}
 	} else {
 		listener, err = net.Listen("tcp", address)
 	}
 	if err != nil {
-		return fmt.Errorf("failed to listen on %s: %v", address, err)
+		return nil, fmt.Errorf("failed to listen on %s: %v", address, err)
 	}
-
-	return r.StartWithListener(listener)
+	return listener, nil
 }
 
-// StartWithListener starts the JSON-RPC server with a given net.Listener
+// StartWithListener starts the JSON-RPC server with a given net.Listener, using Options.Codec.
 // This function blocks execution.
 func (r *JsRPC) StartWithListener(listener net.Listener) error {
+	return r.StartWithListenerCodec(listener, r.options.Codec)
+}
+
+// StartWithListenerCodec starts the JSON-RPC server with a given net.Listener and message codec.
+// With a codec, connections are persistent: any number of framed messages can be exchanged,
+// and requests are dispatched concurrently. With a nil codec, each connection carries a single request.
+// This function blocks execution.
+func (r *JsRPC) StartWithListenerCodec(listener net.Listener, codec Codec) error {
 	if listener == nil {
 		return fmt.Errorf("listener must not be nil")
 	}
 
+	slots := r.limits.conns
+	blocking := slots != nil && !r.options.MaxConnectionsReject
 	for {
+		// Leave connections in the listen backlog while the limit is reached
+		if blocking && !waitConnSlot(listener, slots) {
+			return nil // Listener closed while waiting, exit gracefully
+		}
+
 		conn, err := listener.Accept()
 		if err != nil {
-			r.logger.Printf("Failed to accept connection: %v", err)
+			if blocking {
+				<-slots
+			}
 			if errors.Is(err, net.ErrClosed) {
 				return nil // Listener closed, exit gracefully
 			}
+			r.slog.Error("failed to accept connection", slog.Any("error", err))
+			if r.options.OnAcceptError != nil {
+				r.options.OnAcceptError(err)
+			}
 			continue
 		}
-		go r.handleConnection(conn)
+
+		if slots != nil && !blocking {
+			select {
+			case slots <- struct{}{}:
+			default:
+				r.metrics.rejectedConns.Add(1)
+				r.slog.Warn("connection limit reached, closing connection", slog.String("remote", conn.RemoteAddr().String()))
+				conn.Close()
+				continue
+			}
+		}
+
+		go func() {
+			if slots != nil {
+				defer func() { <-slots }()
+			}
+			r.handleConnection(conn, codec)
+		}()
 	}
 }
 
-// handleConnection reads from a connection, processes the JSON-RPC request, and writes the response
-func (r *JsRPC) handleConnection(conn net.Conn) {
-	defer conn.Close()
+// handleConnection reads from a connection, processes the JSON-RPC requests, and writes the responses
+func (r *JsRPC) handleConnection(conn net.Conn, codec Codec) {
+	if r.options.OnConnect != nil {
+		if err := r.options.OnConnect(conn); err != nil {
+			r.slog.Info("connection rejected", slog.String("remote", conn.RemoteAddr().String()), slog.Any("error", err))
+			conn.Close()
+			return
+		}
+	}
+	connectedAt := time.Now()
+	defer func() {
+		conn.Close()
+		if r.options.OnDisconnect != nil {
+			r.options.OnDisconnect(conn, time.Since(connectedAt))
+		}
+	}()
+
+	r.metrics.activeConns.Add(1)
+	defer r.metrics.activeConns.Add(-1)
+
+	t := &transport{remoteAddr: conn.RemoteAddr().String(), conn: conn}
+	var rw net.Conn = conn
+	if dc := r.newDeadlineConn(conn); dc != nil {
+		rw = dc
+		first := true
+		t.awaitMessage = func() {
+			timeout := r.options.IdleTimeout
+			if first && r.options.FirstByteTimeout > 0 {
+				timeout = r.options.FirstByteTimeout
+			}
+			first = false
+			dc.awaitMessage(timeout)
+		}
+		t.activity = dc.activity
+	}
+
+	// Serve framed messages until the client disconnects
+	if codec != nil {
+		err := r.serveStream(codec.NewFramer(rw), t, conn.Close)
+		switch {
+		case isTimeout(err):
+			r.slog.Info("connection timed out", slog.String("remote", t.remoteAddr))
+		case err != nil && !errors.Is(err, net.ErrClosed):
+			r.slog.Warn("connection closed", slog.String("remote", t.remoteAddr), slog.Any("error", err))
+		}
+		return
+	}
 
 	// Execute command from connection
-	if err := r.ExecuteCommand(conn, conn); err != nil {
-		r.logger.Printf("Error processing request: %v", err)
+	if dc, ok := rw.(*deadlineConn); ok {
+		dc.awaitMessage(r.options.FirstByteTimeout)
+	}
+	err := r.executeCommandWithData(rw, rw, nil, t)
+	if t.drainInput {
+		drainConn(conn)
 	}
+	switch {
+	case isTimeout(err):
+		r.slog.Info("connection timed out", slog.String("remote", t.remoteAddr))
+	case err != nil:
+		r.slog.Error("error processing request", slog.String("remote", t.remoteAddr), slog.Any("error", err))
+	}
+}
+
+// drainConn closes the write side of conn and discards its input for a short while,
+// so that the peer can read the response before the connection is closed
+func drainConn(conn net.Conn) {
+	if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+		_ = cw.CloseWrite()
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+	_, _ = io.Copy(io.Discard, conn)
+}
+
+// transport describes where a request came from.
+// A nil *transport is valid and means the request has no transport details.
+type transport struct {
+	ctx         context.Context   // Parent context of the request, context.Background() when nil
+	remoteAddr  string            // Remote address of the peer, if known
+	httpRequest *http.Request     // HTTP request, for HTTP-based transports
+	conn        net.Conn          // Connection accepted by a listener
+	stream      bool              // Request read from a persistent message stream
+	peer        *Peer             // Persistent connection, for stream transports
+	cgiEnv      map[string]string // CGI environment variables, for CGI and FastCGI
+
+	// drainInput is set when a request was rejected before being read completely: the rest of
+	// the input is drained before closing, so the response is not lost to a connection reset
+	drainInput bool
+
+	// awaitMessage is called by stream transports before reading each message,
+	// letting listeners apply their idle and read timeouts
+	awaitMessage func()
+	// activity is called as requests, outgoing calls and subscriptions of a stream start (1)
+	// and end (-1), so that listeners do not apply their idle timeout to a busy connection
+	activity func(delta int)
+
+	// beforeResponse is called right before a JSON-RPC response is encoded,
+	// letting transports with headers (HTTP) set the status and headers
+	beforeResponse func(ctx *Context, response *JSONRPCResponse)
+	// afterDispatch is called once the request has been dispatched
+	afterDispatch func(ctx *Context)
 }
 
 // ExecuteCommand reads from io.Reader, processes the JSON-RPC request, and writes the response to io.Writer
 func (r *JsRPC) ExecuteCommand(reader io.Reader, writer io.Writer) error {
-	return r.executeCommandWithData(reader, writer, nil)
+	return r.executeCommandWithData(reader, writer, nil, nil)
+}
+
+// ExecuteCommandContext works like ExecuteCommand, using c as the parent context of the request.
+// Use ExtractTraceHeaders on c to join the caller's trace when serving HTTP.
+func (r *JsRPC) ExecuteCommandContext(c context.Context, reader io.Reader, writer io.Writer) error {
+	return r.executeCommandWithData(reader, writer, nil, &transport{ctx: c})
 }
 
 // ExecuteCommandWithData reads from io.Reader, processes the JSON-RPC request, and writes the response to io.Writer.
 // It also accepts a map of data that can be shared between middlewares and handlers through the data field in the context.
 func (r *JsRPC) ExecuteCommandWithData(reader io.Reader, writer io.Writer, data map[string]interface{}) error {
-	return r.executeCommandWithData(reader, writer, data)
+	return r.executeCommandWithData(reader, writer, data, nil)
 }
 
-func (r *JsRPC) executeCommandWithData(reader io.Reader, writer io.Writer, data map[string]interface{}) error {
+func (r *JsRPC) executeCommandWithData(reader io.Reader, writer io.Writer, data map[string]interface{}, t *transport) error {
+	if t == nil {
+		t = &transport{}
+	}
+
+	// Plain CGI mode (ExecuteCommand on stdin/stdout): the CGI headers are written exactly once,
+	// before whatever is written first, including interceptor output and error responses.
+	// The response goes to stdout, so the default logger moves to stderr.
+	if r.cgi && t.httpRequest == nil && t.conn == nil && !t.stream {
+		r.logToStderr()
+		res := &httpResponse{w: newCGIResponseWriter(writer)}
+		t = &transport{ctx: t.ctx, remoteAddr: t.remoteAddr, beforeResponse: res.beforeResponse, afterDispatch: res.afterDispatch}
+		writer = res
+		defer res.finish()
+	}
+
 	// Intercept the request if a handler interceptor is defined
 	if r.options.HandlerInterceptor != nil {
 		finished, err := r.options.HandlerInterceptor(reader, writer)
@@ -92,56 +260,170 @@ func (r *JsRPC) executeCommandWithData(reader io.Reader, writer io.Writer, data
 		}
 	}
 
+	// Count request and response sizes for metrics
+	requestCounter := &countingReader{reader: reader}
+	responseCounter := &countingWriter{writer: writer}
+
 	// Decode the JSON-RPC request
 	var rpcRequest JSONRPCRequest
 
-	if err := json.NewDecoder(reader).Decode(&rpcRequest); err != nil {
-		r.logger.Printf("Invalid JSON: %v", err)
+	if err := r.decodeRequest(requestCounter, &rpcRequest); err != nil {
+		if isTimeout(err) {
+			return fmt.Errorf("read request: %w", err) // No response to a client too slow to send its request
+		}
+		var limitErr *limitError
+		if errors.As(err, &limitErr) {
+			r.slog.Warn("request rejected", slog.String("remote", t.remoteAddr), slog.String("reason", limitErr.reason))
+			ctx := &Context{writer: writer, server: r, transport: t, ID: limitErr.id, RemoteAddr: t.remoteAddr, Logger: r.logger, Slog: r.slog, stdctx: t.ctx}
+			r.requestError(ctx, err)
+			_ = ctx.ErrorString(RequestTooLarge, limitErr.reason)
+			t.drainInput = t.conn != nil
+			return nil
+		}
+		if errors.Is(err, errInvalidRequest) {
+			r.slog.Warn("invalid request", slog.String("remote", t.remoteAddr))
+			ctx := &Context{writer: writer, server: r, transport: t, RemoteAddr: t.remoteAddr, Logger: r.logger, Slog: r.slog, stdctx: t.ctx}
+			r.requestError(ctx, err)
+			_ = ctx.ErrorString(InvalidRequest, "invalid request, expected a request object (batches are not supported)")
+			return nil
+		}
+		r.slog.Warn("invalid JSON", slog.Any("error", err))
+		ctx := &Context{writer: writer, server: r, transport: t, RemoteAddr: t.remoteAddr, Logger: r.logger, Slog: r.slog, stdctx: t.ctx}
+		r.requestError(ctx, fmt.Errorf("parse error: %w", err))
+		_ = ctx.ErrorString(ParseError, "parse error")
 		return nil
 	}
 
 	ctx := &Context{
-		Method: rpcRequest.Method,
-		Params: rpcRequest.Params,
-		writer: writer,
-		Logger: r.logger,
-		ID:     rpcRequest.ID,
-		data:   data,
-		cgi:    r.cgi,
+		Method:       rpcRequest.Method,
+		Params:       rpcRequest.Params,
+		writer:       responseCounter,
+		Logger:       r.logger,
+		ID:           rpcRequest.ID,
+		RemoteAddr:   t.remoteAddr,
+		data:         data,
+		meta:         rpcRequest.Meta,
+		server:       r,
+		stdctx:       t.ctx,
+		transport:    t,
+		notification: rpcRequest.ID == nil,
+	}
+	if ctx.stdctx == nil {
+		ctx.stdctx = context.Background()
+	}
+
+	// Join the caller's trace when it was not propagated by the transport (e.g. over TCP)
+	if !SpanContextFromContext(ctx.stdctx).IsValid() {
+		ctx.stdctx = extractMetaTraceContext(ctx.stdctx, rpcRequest.Meta)
+	}
+
+	// Start the request span; middlewares and the handler get child spans
+	span, endSpan := r.startSpan(ctx, rpcRequest.Method,
+		Attribute{Key: AttrRPCSystem, Value: "jsonrpc"},
+		Attribute{Key: AttrRPCMethod, Value: rpcRequest.Method},
+	)
+	if ctx.ID != nil {
+		span.SetAttributes(Attribute{Key: AttrRequestID, Value: ctx.ID})
 	}
+	ctx.Slog = r.requestLogger(ctx)
 
 	cmd, exists := r.handlers[rpcRequest.Method]
+
+	failed := false // A middleware or the handler returned an error
+	start := time.Now()
+	r.metrics.inFlight.Add(1)
+	defer func() {
+		if p := recover(); p != nil {
+			ctx.Slog.Error("handler panic", slog.Any("panic", p), slog.String("stack", string(debug.Stack())))
+			span.SetError(fmt.Errorf("panic: %v", p))
+			r.requestError(ctx, fmt.Errorf("panic: %v", p))
+			if !ctx.responded {
+				_ = ctx.ErrorString(InternalError, "internal error")
+			}
+		}
+		if !ctx.responded && ctx.requestCancelled() {
+			_ = ctx.ErrorString(RequestCancelled, "request cancelled")
+		}
+		// A request with an ID is always answered, even when a middleware or the handler failed
+		// without writing: otherwise HTTP clients get an empty body and stream clients wait forever
+		if !ctx.responded && !ctx.notification {
+			if !failed {
+				ctx.Slog.Warn("handler returned without a response")
+			}
+			_ = ctx.ErrorString(InternalError, "internal error")
+		}
+		if ctx.errorCode != 0 {
+			span.SetAttributes(Attribute{Key: AttrErrorCode, Value: ctx.errorCode})
+		}
+		span.SetAttributes(
+			Attribute{Key: AttrRequestSize, Value: requestCounter.n},
+			Attribute{Key: AttrResponseSize, Value: responseCounter.n},
+		)
+		endSpan()
+		elapsed := time.Since(start)
+		r.metrics.inFlight.Add(-1)
+		metricsMethod := ctx.Method
+		if !exists {
+			metricsMethod = unknownMethodLabel
+		}
+		r.metrics.observe(metricsMethod, ctx.errorCode, elapsed, requestCounter.n, responseCounter.n)
+		r.logRequest(ctx, &rpcRequest, elapsed)
+		if t.afterDispatch != nil {
+			t.afterDispatch(ctx)
+		}
+		if ctx.session != nil {
+			ctx.session.close()
+		}
+	}()
+
 	if !exists {
-		r.logger.Printf("Command not found: %s", rpcRequest.Method)
+		ctx.Slog.Warn("method not found")
 		_ = ctx.ErrorString(MethodNotFound, "method not found")
 		return nil
 	}
 
+	// Wait for a worker when Options.MaxWorkers requests are already executing. The request is
+	// decoded first, so a client slow to send it does not hold a worker.
+	release, err := r.acquireWorker(ctx.stdctx)
+	if err != nil {
+		ctx.Slog.Debug("request stopped while waiting for a worker", slog.Any("error", err))
+		return nil
+	}
+	defer release()
+
 	// Execute global middlewares
-	for _, middleware := range r.middlewares {
-		if err := middleware(ctx); err != nil {
+	for i, middleware := range r.middlewares {
+		if err := r.tracedCall(ctx, "middleware", middleware, Attribute{Key: AttrScope, Value: "global"}, Attribute{Key: AttrMiddleware, Value: i}); err != nil {
 			// Stop execution if a global middleware returns an error
+			ctx.Slog.Debug("request stopped by middleware", slog.Any("error", err))
+			failed = true
 			return nil
 		}
 	}
 
 	// Execute command-specific middlewares
-	for _, middleware := range cmd.middlewares {
-		if err := middleware(ctx); err != nil {
+	for i, middleware := range cmd.middlewares {
+		if err := r.tracedCall(ctx, "middleware", middleware, Attribute{Key: AttrScope, Value: "command"}, Attribute{Key: AttrMiddleware, Value: i}); err != nil {
 			// Stop execution if a command-specific middleware returns an error
+			ctx.Slog.Debug("request stopped by middleware", slog.Any("error", err))
+			failed = true
 			return nil
 		}
 	}
 
 	// Execute handler and log any returned error
-	if err := cmd.handler(ctx); err != nil {
-		r.logger.Printf("Handler error: %v", err)
+	if err := r.tracedCall(ctx, "handler", cmd.handler); err != nil {
+		ctx.Slog.Error("handler error", slog.Any("error", err))
+		r.requestError(ctx, err)
+		failed = true
 		return nil
 	}
+	return nil
+}
 
-	// log if option is enabled
-	if r.options.LogRequests {
-		r.logger.Printf("Request: %v %#v", rpcRequest.Method, rpcRequest.Params)
+// requestError reports a failed request to the OnRequestError hook
+func (r *JsRPC) requestError(ctx *Context, err error) {
+	if r.options.OnRequestError != nil {
+		r.options.OnRequestError(ctx, err)
 	}
-	return nil
 }
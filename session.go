@@ -0,0 +1,90 @@
+// session.go
+package go_jsonrpc
+
+import "sync"
+
+// Session holds state shared by the requests of one connection, such as the user authenticated
+// by a login call. It is safe for concurrent use.
+type Session struct {
+	mu      sync.RWMutex
+	values  map[string]any
+	onClose []func()
+	closed  bool
+}
+
+func newSession() *Session {
+	return &Session{values: make(map[string]any)}
+}
+
+// Set stores a value in the session
+func (s *Session) Set(name string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.values[name] = value
+}
+
+// Get returns a value stored in the session, or nil
+func (s *Session) Get(name string) any {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.values[name]
+}
+
+// Delete removes a value from the session
+func (s *Session) Delete(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.values, name)
+}
+
+// OnClose registers a function called when the connection closes, to release per-connection resources.
+// It is called right away if the session is already closed.
+func (s *Session) OnClose(fn func()) {
+	s.mu.Lock()
+	if !s.closed {
+		s.onClose = append(s.onClose, fn)
+		s.mu.Unlock()
+		return
+	}
+	s.mu.Unlock()
+	fn()
+}
+
+// close clears the session and runs the OnClose functions, in reverse order of registration
+func (s *Session) close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	s.values = make(map[string]any)
+	onClose := s.onClose
+	s.onClose = nil
+	s.mu.Unlock()
+
+	for i := len(onClose) - 1; i >= 0; i-- {
+		onClose[i]()
+	}
+}
+
+// Session returns the state of the connection the request arrived on. On persistent connections
+// (sockets with a codec, stdio and WebSocket) it lives until the connection closes; on single-request
+// transports it only lives for the request.
+func (ctx *Context) Session() *Session {
+	if peer := ctx.Peer(); peer != nil {
+		return peer.session
+	}
+	if ctx.session == nil {
+		ctx.session = newSession()
+	}
+	return ctx.session
+}
+
+// Session returns the state of the connection
+func (p *Peer) Session() *Session {
+	return p.session
+}
@@ -0,0 +1,44 @@
+// stdio.go
+package go_jsonrpc
+
+import (
+	"io"
+	"os"
+)
+
+// ServeStdio serves a continuous stream of JSON-RPC messages read from stdin, writing the responses to stdout.
+// Messages are framed with LSP-style Content-Length headers when Options.StdioContentLength is set,
+// otherwise with Options.Codec, or as newline-delimited JSON when it is nil. Requests are dispatched concurrently and notifications get no response.
+// Stdout is reserved for the protocol: the default logger is redirected to stderr.
+// This function blocks execution until stdin is closed.
+func (r *JsRPC) ServeStdio() error {
+	r.logToStderr()
+	return r.ServeStream(os.Stdin, os.Stdout)
+}
+
+// ServeStream serves a continuous stream of JSON-RPC messages read from reader, writing the responses
+// to writer, with the same framing as ServeStdio. It returns when reader reaches EOF.
+func (r *JsRPC) ServeStream(reader io.Reader, writer io.Writer) error {
+	return r.ServeStreamCodec(reader, writer, r.stdioCodec())
+}
+
+// ServeStreamCodec works like ServeStream with an explicit codec
+func (r *JsRPC) ServeStreamCodec(reader io.Reader, writer io.Writer, codec Codec) error {
+	rw := struct {
+		io.Reader
+		io.Writer
+	}{reader, writer}
+	return r.serveStream(codec.NewFramer(rw), &transport{}, nil)
+}
+
+// stdioCodec returns the codec used by ServeStdio and ServeStream
+func (r *JsRPC) stdioCodec() Codec {
+	switch {
+	case r.options.StdioContentLength:
+		return ContentLengthCodec{}
+	case r.options.Codec != nil:
+		return r.options.Codec
+	default:
+		return NDJSONCodec{}
+	}
+}
@@ -0,0 +1,179 @@
+// stream.go
+package go_jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// DefaultStreamChunkMethod is the notification carrying the items of a streamed result on persistent connections
+const DefaultStreamChunkMethod = "$/streamChunk"
+
+// DefaultStreamChunkSize is the number of items per chunk when Options.StreamChunkSize is 0
+const DefaultStreamChunkSize = 100
+
+// StreamResult is the final result of a result streamed on a persistent connection
+type StreamResult struct {
+	Count int `json:"count"` // Number of items sent in chunks
+}
+
+// streamChunk are the params of a chunk notification
+type streamChunk struct {
+	ID    any               `json:"id"`
+	Items []json.RawMessage `json:"items"`
+}
+
+// StreamArray sends an array result item by item, so that large results never have to be held in memory.
+// produce calls yield for each item; yield returns an error when the item could not be sent or the request
+// was cancelled, and produce should then stop and return it.
+//
+// On single-request transports (HTTP, CGI, one-shot sockets) the response is a regular JSON-RPC response
+// whose result array is written, and flushed, incrementally. If produce fails before the first chunk is
+// flushed, the response is an error; if it fails later, the response is left unterminated, so that the
+// client gets a parse error rather than a partial result.
+//
+// On persistent connections the items are sent in chunk notifications ("$/streamChunk", params
+// {"id": <request id>, "items": [...]}) of Options.StreamChunkSize items, followed by the response,
+// whose result is a StreamResult, or an error if produce failed.
+func (ctx *Context) StreamArray(produce func(yield func(item any) error) error) error {
+	if ctx.notification {
+		return produce(func(any) error { return ctx.Context().Err() })
+	}
+	if peer := ctx.Peer(); peer != nil {
+		return ctx.streamChunks(peer, produce)
+	}
+	return ctx.streamInline(produce)
+}
+
+// streamInline writes the result array incrementally in the response. Nothing is written before the
+// first chunk is complete, so a producer failing early is answered with a regular error response.
+func (ctx *Context) streamInline(produce func(yield func(item any) error) error) error {
+	chunkSize := ctx.server.streamChunkSize()
+	id, err := json.Marshal(ctx.ID)
+	if err != nil {
+		return fmt.Errorf("marshal id: %w", err)
+	}
+
+	var buf bytes.Buffer
+	started := false
+	flush := func() error {
+		if !started {
+			started = true
+			ctx.responded = true
+			if ctx.transport != nil && ctx.transport.beforeResponse != nil {
+				ctx.transport.beforeResponse(ctx, &JSONRPCResponse{JSONRPC: "2.0", Result: []any{}, ID: ctx.ID})
+			}
+		}
+		_, err := ctx.writer.Write(buf.Bytes())
+		buf.Reset()
+		if err != nil {
+			return err
+		}
+		if f, ok := ctx.writer.(http.Flusher); ok {
+			f.Flush()
+		}
+		return nil
+	}
+
+	buf.WriteString(`{"jsonrpc":"2.0","result":[`)
+	count := 0
+	produceErr := produce(func(item any) error {
+		if err := ctx.Context().Err(); err != nil {
+			return err
+		}
+		b, err := json.Marshal(item)
+		if err != nil {
+			return fmt.Errorf("marshal stream item: %w", err)
+		}
+		if count > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(b)
+		count++
+		if count%chunkSize == 0 {
+			return flush()
+		}
+		return nil
+	})
+
+	if produceErr != nil {
+		if !started {
+			if !ctx.requestCancelled() {
+				_ = ctx.ErrorString(InternalError, produceErr.Error())
+			}
+			return produceErr
+		}
+		// A response with both a result and an error is invalid, and a partial result must not pass
+		// for a complete one: the response is left unterminated, so the client fails to parse it
+		ctx.errorCode = InternalError
+		return fmt.Errorf("stream aborted after %d items: %w", count, produceErr)
+	}
+
+	buf.WriteString(`],"id":`)
+	buf.Write(id)
+	buf.WriteString("}\n")
+	return flush()
+}
+
+// streamChunks sends the items in chunk notifications, then the final response
+func (ctx *Context) streamChunks(peer *Peer, produce func(yield func(item any) error) error) error {
+	method := ctx.server.streamChunkMethod()
+	chunkSize := ctx.server.streamChunkSize()
+	chunk := make([]json.RawMessage, 0, chunkSize)
+	count := 0
+
+	send := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		msg, err := json.Marshal(JSONRPCRequest{JSONRPC: "2.0", Method: method, Params: streamChunk{ID: ctx.ID, Items: chunk}})
+		chunk = chunk[:0]
+		if err != nil {
+			return fmt.Errorf("marshal stream chunk: %w", err)
+		}
+		return peer.send(msg)
+	}
+
+	err := produce(func(item any) error {
+		if err := ctx.Context().Err(); err != nil {
+			return err
+		}
+		b, err := json.Marshal(item)
+		if err != nil {
+			return fmt.Errorf("marshal stream item: %w", err)
+		}
+		chunk = append(chunk, b)
+		count++
+		if len(chunk) == chunkSize {
+			return send()
+		}
+		return nil
+	})
+	// Send the last items, even when produce failed after yielding them
+	if sendErr := send(); err == nil {
+		err = sendErr
+	}
+	if err != nil {
+		_ = ctx.ErrorString(InternalError, err.Error())
+		return err
+	}
+	return ctx.JSON(StreamResult{Count: count})
+}
+
+// streamChunkMethod returns the name of the chunk notification
+func (r *JsRPC) streamChunkMethod() string {
+	if r == nil || r.options.StreamChunkMethod == "" {
+		return DefaultStreamChunkMethod
+	}
+	return r.options.StreamChunkMethod
+}
+
+// streamChunkSize returns the number of items per chunk
+func (r *JsRPC) streamChunkSize() int {
+	if r == nil || r.options.StreamChunkSize <= 0 {
+		return DefaultStreamChunkSize
+	}
+	return r.options.StreamChunkSize
+}
@@ -0,0 +1,194 @@
+// subscription.go
+package go_jsonrpc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrSubscriptionClosed is returned when notifying a subscription that has ended
+var ErrSubscriptionClosed = errors.New("subscription closed")
+
+// SubscriptionFunc handles a subscribe request. It validates the params, starts producing events
+// (usually in a goroutine) that are sent through sink, and returns. Events sent before the subscription ID
+// reaches the client are queued. Returning an error rejects the subscription; unless the handler
+// already responded, the client then gets an InternalError response with the error message.
+type SubscriptionFunc func(ctx *Context, sink *Sink) error
+
+// RegisterSubscription registers an Ethereum-style subscription API under a namespace:
+//
+//   - "<namespace>_subscribe" calls handler and returns the subscription ID
+//   - "<namespace>_unsubscribe" takes the subscription ID and returns whether it was active
+//   - events are sent as "<namespace>_subscription" notifications with {"subscription": id, "result": event}
+//
+// Subscriptions are bound to the caller's persistent connection and end automatically when the client
+// unsubscribes or disconnects. The middlewares apply to both methods.
+func (r *JsRPC) RegisterSubscription(namespace string, handler SubscriptionFunc, middlewares ...MiddlewareFunc) {
+	r.RegisterCommand(namespace+"_subscribe", func(ctx *Context) error {
+		peer := ctx.Peer()
+		if peer == nil {
+			return ctx.ErrorString(InvalidRequest, "subscriptions require a persistent connection")
+		}
+
+		sink := peer.newSink(namespace)
+		if err := handler(ctx, sink); err != nil {
+			sink.Close()
+			if !ctx.responded {
+				_ = ctx.ErrorString(InternalError, err.Error())
+			}
+			return err
+		}
+
+		err := ctx.JSON(sink.id)
+		sink.activate()
+		return err
+	}, middlewares...)
+
+	r.RegisterCommand(namespace+"_unsubscribe", func(ctx *Context) error {
+		peer := ctx.Peer()
+		if peer == nil {
+			return ctx.ErrorString(InvalidRequest, "subscriptions require a persistent connection")
+		}
+
+		var params []string
+		if err := ctx.Bind(&params); err != nil || len(params) != 1 {
+			return ctx.ErrorString(InvalidParams, "expected [subscriptionID]")
+		}
+		sink := peer.sink(params[0])
+		if sink == nil || sink.namespace != namespace {
+			return ctx.JSON(false)
+		}
+		sink.Close()
+		return ctx.JSON(true)
+	}, middlewares...)
+}
+
+// Sink delivers the events of a subscription to the subscribed connection
+type Sink struct {
+	id        string
+	namespace string
+	peer      *Peer
+	mu        sync.Mutex
+	active    bool     // The subscription ID has been sent to the client
+	queued    [][]byte // Events sent before activation
+	closed    bool
+	done      chan struct{}
+}
+
+// subscriptionEvent is the params object of a subscription notification
+type subscriptionEvent struct {
+	Subscription string `json:"subscription"`
+	Result       any    `json:"result"`
+}
+
+// ID returns the subscription ID
+func (s *Sink) ID() string {
+	return s.id
+}
+
+// Done returns a channel closed when the subscription ends: the client unsubscribed or
+// disconnected, or the server closed it. Producers should stop when it is closed.
+func (s *Sink) Done() <-chan struct{} {
+	return s.done
+}
+
+// Notify sends an event to the subscriber
+func (s *Sink) Notify(result any) error {
+	msg, err := json.Marshal(JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  s.namespace + "_subscription",
+		Params:  subscriptionEvent{Subscription: s.id, Result: result},
+	})
+	if err != nil {
+		return fmt.Errorf("marshal subscription event: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrSubscriptionClosed
+	}
+	if !s.active {
+		s.queued = append(s.queued, msg)
+		return nil
+	}
+	return s.peer.send(msg)
+}
+
+// Close ends the subscription from the server side
+func (s *Sink) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	s.queued = nil
+	close(s.done)
+	s.peer.removeSink(s.id)
+}
+
+// activate flushes the queued events once the subscription ID has been sent
+func (s *Sink) activate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	for _, msg := range s.queued {
+		if err := s.peer.send(msg); err != nil {
+			break
+		}
+	}
+	s.queued = nil
+	s.active = true
+}
+
+// newSink creates and registers a subscription on the peer
+func (p *Peer) newSink(namespace string) *Sink {
+	sink := &Sink{
+		id:        "0x" + newPeerID(),
+		namespace: namespace,
+		peer:      p,
+		done:      make(chan struct{}),
+	}
+	p.subsMu.Lock()
+	defer p.subsMu.Unlock()
+	if p.subs == nil {
+		p.subs = make(map[string]*Sink)
+	}
+	p.subs[sink.id] = sink
+	p.activity(1)
+	return sink
+}
+
+func (p *Peer) sink(id string) *Sink {
+	p.subsMu.Lock()
+	defer p.subsMu.Unlock()
+	return p.subs[id]
+}
+
+func (p *Peer) removeSink(id string) {
+	p.subsMu.Lock()
+	defer p.subsMu.Unlock()
+	if _, ok := p.subs[id]; ok {
+		delete(p.subs, id)
+		p.activity(-1)
+	}
+}
+
+// closeSubscriptions ends every subscription of the peer, when the connection goes away
+func (p *Peer) closeSubscriptions() {
+	p.subsMu.Lock()
+	sinks := make([]*Sink, 0, len(p.subs))
+	for _, sink := range p.subs {
+		sinks = append(sinks, sink)
+	}
+	p.subsMu.Unlock()
+
+	for _, sink := range sinks {
+		sink.Close()
+	}
+}
@@ -0,0 +1,219 @@
+// trace.go
+package go_jsonrpc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Tracer starts spans around request dispatch.
+// Implement it on top of OpenTelemetry or any other tracing system; the library only depends on this interface.
+type Tracer interface {
+	// Start creates a span as a child of the span found in ctx (if any)
+	// and returns a context carrying the new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a unit of work started by a Tracer
+type Span interface {
+	SpanContext() SpanContext         // Identifiers of the span
+	SetAttributes(attrs ...Attribute) // Adds attributes to the span
+	SetError(err error)               // Marks the span as failed
+	End()                             // Finishes the span
+}
+
+// Attribute is a key/value pair recorded on a span
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// SpanContext identifies a span within a trace
+type SpanContext struct {
+	TraceID    [16]byte
+	SpanID     [8]byte
+	Flags      byte   // Trace flags, 0x01 means sampled
+	TraceState string // Vendor-specific W3C tracestate, propagated as is
+}
+
+// IsValid reports whether both the trace and span IDs are set
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// TraceIDString returns the trace ID in lowercase hex
+func (sc SpanContext) TraceIDString() string {
+	return hex.EncodeToString(sc.TraceID[:])
+}
+
+// SpanIDString returns the span ID in lowercase hex
+func (sc SpanContext) SpanIDString() string {
+	return hex.EncodeToString(sc.SpanID[:])
+}
+
+// Span attribute keys recorded by the dispatcher
+const (
+	AttrRPCSystem    = "rpc.system"
+	AttrRPCMethod    = "rpc.method"
+	AttrRequestID    = "rpc.jsonrpc.request_id"
+	AttrErrorCode    = "rpc.jsonrpc.error_code"
+	AttrRequestSize  = "rpc.request.size"
+	AttrResponseSize = "rpc.response.size"
+	AttrMiddleware   = "rpc.middleware.index"
+	AttrScope        = "rpc.middleware.scope"
+)
+
+type spanKey struct{}
+
+// ContextWithSpan returns a copy of ctx carrying span
+func ContextWithSpan(ctx context.Context, span Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext returns the span stored in ctx, or nil
+func SpanFromContext(ctx context.Context) Span {
+	span, _ := ctx.Value(spanKey{}).(Span)
+	return span
+}
+
+// noopTracer is used when no Tracer is configured
+type noopTracer struct{}
+
+func (noopTracer) Start(ctx context.Context, _ string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SpanContext() SpanContext   { return SpanContext{} }
+func (noopSpan) SetAttributes(...Attribute) {}
+func (noopSpan) SetError(error)             {}
+func (noopSpan) End()                       {}
+
+// startSpan starts a child span of the current context and makes it the current one
+// until the returned function is called.
+func (r *JsRPC) startSpan(ctx *Context, name string, attrs ...Attribute) (Span, func()) {
+	parent := ctx.Context()
+	child, span := r.tracer.Start(parent, name)
+	span.SetAttributes(attrs...)
+	ctx.stdctx = child
+	return span, func() {
+		ctx.stdctx = parent
+		span.End()
+	}
+}
+
+// tracedCall runs fn inside a child span
+func (r *JsRPC) tracedCall(ctx *Context, name string, fn func(*Context) error, attrs ...Attribute) error {
+	span, end := r.startSpan(ctx, name, attrs...)
+	defer end()
+	err := fn(ctx)
+	if err != nil {
+		span.SetError(err)
+	}
+	return err
+}
+
+// RecorderTracer is an in-memory Tracer that keeps every finished span.
+// It is meant for tests and debugging.
+type RecorderTracer struct {
+	mu    sync.Mutex
+	spans []RecordedSpan
+}
+
+// RecordedSpan is a finished span captured by RecorderTracer
+type RecordedSpan struct {
+	Name        string
+	SpanContext SpanContext
+	Parent      SpanContext // Zero when the span is a root span; may belong to a remote caller
+	Attributes  map[string]any
+	Err         error
+	Start       time.Time
+	End         time.Time
+}
+
+// NewRecorderTracer creates an empty RecorderTracer
+func NewRecorderTracer() *RecorderTracer {
+	return &RecorderTracer{}
+}
+
+// Start implements Tracer
+func (t *RecorderTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	span := &recorderSpan{
+		tracer: t,
+		data: RecordedSpan{
+			Name:       name,
+			Attributes: make(map[string]any),
+			Start:      time.Now(),
+		},
+	}
+	span.data.Parent = SpanContextFromContext(ctx)
+	if span.data.Parent.IsValid() {
+		span.data.SpanContext.TraceID = span.data.Parent.TraceID
+		span.data.SpanContext.Flags = span.data.Parent.Flags
+		span.data.SpanContext.TraceState = span.data.Parent.TraceState
+	} else {
+		_, _ = rand.Read(span.data.SpanContext.TraceID[:])
+		span.data.SpanContext.Flags = 0x01
+	}
+	_, _ = rand.Read(span.data.SpanContext.SpanID[:])
+	return ContextWithSpan(ctx, span), span
+}
+
+// Spans returns the finished spans in the order they ended
+func (t *RecorderTracer) Spans() []RecordedSpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]RecordedSpan(nil), t.spans...)
+}
+
+// Reset discards the recorded spans
+func (t *RecorderTracer) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.spans = nil
+}
+
+type recorderSpan struct {
+	tracer *RecorderTracer
+	mu     sync.Mutex
+	data   RecordedSpan
+	ended  bool
+}
+
+func (s *recorderSpan) SpanContext() SpanContext {
+	return s.data.SpanContext
+}
+
+func (s *recorderSpan) SetAttributes(attrs ...Attribute) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, attr := range attrs {
+		s.data.Attributes[attr.Key] = attr.Value
+	}
+}
+
+func (s *recorderSpan) SetError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Err = err
+}
+
+func (s *recorderSpan) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+
+	s.tracer.mu.Lock()
+	s.tracer.spans = append(s.tracer.spans, data)
+	s.tracer.mu.Unlock()
+}
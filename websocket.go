@@ -0,0 +1,402 @@
+// websocket.go
+package go_jsonrpc
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultWebSocketMaxMessageSize is the message size limit when WebSocketOptions.MaxMessageSize is 0
+const DefaultWebSocketMaxMessageSize = 1 << 20
+
+// WebSocket close codes (RFC 6455, section 7.4.1)
+const (
+	WSCloseNormal          = 1000
+	WSCloseGoingAway       = 1001
+	WSCloseProtocolError   = 1002
+	WSCloseUnsupportedData = 1003
+	WSCloseInvalidPayload  = 1007
+	WSClosePolicyViolation = 1008
+	WSCloseMessageTooBig   = 1009
+	WSCloseInternalError   = 1011
+)
+
+// websocketGUID is appended to the client key to compute Sec-WebSocket-Accept
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket opcodes
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+)
+
+// WebSocketOptions configures the WebSocket transport
+type WebSocketOptions struct {
+	// MaxMessageSize limits the payload of a frame and of a reassembled fragmented message.
+	// Larger messages close the connection with WSCloseMessageTooBig. 0 means DefaultWebSocketMaxMessageSize.
+	MaxMessageSize int64
+	// CheckOrigin decides whether the handshake is accepted for the request origin.
+	// When nil, Options.CORS is used if set, otherwise only same-host origins are accepted.
+	CheckOrigin func(req *http.Request) bool
+	// PingInterval is how often the server pings the client to keep the connection alive. 0 disables pings.
+	PingInterval time.Duration
+}
+
+// WebSocketHandler returns an http.Handler upgrading requests to WebSocket connections (RFC 6455).
+// Each text message is dispatched as a JSON-RPC request and the responses are sent back as text messages,
+// so the connection supports concurrent requests and server-initiated messages. Ping, pong and close frames
+// are handled by the transport. The handshake request is available to handlers through Context.HTTPRequest.
+func (r *JsRPC) WebSocketHandler(opts *WebSocketOptions) http.Handler {
+	if opts == nil {
+		opts = &WebSocketOptions{}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.serveWebSocket(w, req, opts)
+	})
+}
+
+func (r *JsRPC) serveWebSocket(w http.ResponseWriter, req *http.Request, opts *WebSocketOptions) {
+	if req.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if !headerContainsToken(req.Header, "Connection", "upgrade") || !headerContainsToken(req.Header, "Upgrade", "websocket") {
+		http.Error(w, "websocket upgrade required", http.StatusUpgradeRequired)
+		return
+	}
+	if req.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+		return
+	}
+	key := req.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		http.Error(w, "invalid Sec-WebSocket-Key", http.StatusBadRequest)
+		return
+	}
+	if !r.checkWebSocketOrigin(req, opts) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported by the server", http.StatusInternalServerError)
+		return
+	}
+	netConn, brw, err := hijacker.Hijack()
+	if err != nil {
+		r.slog.Error("websocket hijack failed", slog.String("remote", req.RemoteAddr), slog.Any("error", err))
+		return
+	}
+	defer netConn.Close()
+
+	// Complete the handshake
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	handshake := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n"
+	if _, err := brw.WriteString(handshake); err != nil {
+		return
+	}
+	if err := brw.Flush(); err != nil {
+		return
+	}
+
+	maxSize := opts.MaxMessageSize
+	if maxSize <= 0 {
+		maxSize = DefaultWebSocketMaxMessageSize
+	}
+	ws := &wsConn{conn: netConn, reader: brw.Reader, maxSize: maxSize}
+
+	if opts.PingInterval > 0 {
+		done := make(chan struct{})
+		defer close(done)
+		go ws.pingLoop(opts.PingInterval, done)
+	}
+
+	r.metrics.activeConns.Add(1)
+	defer r.metrics.activeConns.Add(-1)
+
+	t := &transport{
+		ctx:         ExtractTraceHeaders(req.Context(), req.Header),
+		remoteAddr:  req.RemoteAddr,
+		httpRequest: req,
+		conn:        netConn,
+	}
+	err = r.serveStream(ws, t, func() error {
+		_ = ws.writeClose(WSCloseGoingAway, "closed by server")
+		return netConn.Close()
+	})
+
+	var closeErr *wsCloseError
+	switch {
+	case err == nil:
+		// The client closed the connection, the close handshake is already done
+	case errors.As(err, &closeErr):
+		_ = ws.writeClose(closeErr.code, closeErr.reason)
+		r.slog.Warn("websocket closed", slog.String("remote", req.RemoteAddr), slog.Int("code", closeErr.code), slog.String("reason", closeErr.reason))
+	case !errors.Is(err, net.ErrClosed):
+		r.slog.Warn("websocket closed", slog.String("remote", req.RemoteAddr), slog.Any("error", err))
+	}
+}
+
+// checkWebSocketOrigin applies WebSocketOptions.CheckOrigin, the CORS policy or a same-host check
+func (r *JsRPC) checkWebSocketOrigin(req *http.Request, opts *WebSocketOptions) bool {
+	if opts.CheckOrigin != nil {
+		return opts.CheckOrigin(req)
+	}
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return true // Not a browser
+	}
+	if r.options.CORS != nil {
+		return r.options.CORS.allowsOrigin(origin)
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, req.Host)
+}
+
+// headerContainsToken reports whether a comma-separated header contains token (case-insensitive)
+func headerContainsToken(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		if containsFold(parseHeaderList(value), token) {
+			return true
+		}
+	}
+	return false
+}
+
+// wsCloseError ends a connection with a close frame carrying code and reason
+type wsCloseError struct {
+	code   int
+	reason string
+}
+
+func (e *wsCloseError) Error() string {
+	return fmt.Sprintf("websocket close %d: %s", e.code, e.reason)
+}
+
+// wsConn is a server-side WebSocket connection implementing Framer
+type wsConn struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	maxSize int64
+	writeMu sync.Mutex // Serializes frames written by responses, pongs, pings and close
+}
+
+// ReadMessage returns the next text message, answering control frames on the way.
+// It returns io.EOF once the client has closed the connection.
+func (c *wsConn) ReadMessage() ([]byte, error) {
+	var message []byte
+	var opcode byte
+	for {
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		switch op {
+		case wsPing:
+			if err := c.writeFrame(wsPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			code := WSCloseNormal
+			switch {
+			case len(payload) == 1:
+				return nil, &wsCloseError{WSCloseProtocolError, "invalid close payload"}
+			case len(payload) >= 2:
+				code = int(binary.BigEndian.Uint16(payload))
+				if !validCloseCode(code) {
+					return nil, &wsCloseError{WSCloseProtocolError, "invalid close code"}
+				}
+				if !utf8.Valid(payload[2:]) {
+					return nil, &wsCloseError{WSCloseInvalidPayload, "invalid UTF-8 in close reason"}
+				}
+			}
+			_ = c.writeClose(code, "") // Echo the client's code, as RFC 6455 suggests
+			return nil, io.EOF
+		case wsText, wsBinary:
+			if opcode != 0 {
+				return nil, &wsCloseError{WSCloseProtocolError, "expected continuation frame"}
+			}
+			opcode = op
+		case wsContinuation:
+			if opcode == 0 {
+				return nil, &wsCloseError{WSCloseProtocolError, "unexpected continuation frame"}
+			}
+		default:
+			return nil, &wsCloseError{WSCloseProtocolError, "unknown opcode"}
+		}
+
+		if int64(len(message)+len(payload)) > c.maxSize {
+			return nil, &wsCloseError{WSCloseMessageTooBig, "message too big"}
+		}
+		message = append(message, payload...)
+		if !fin {
+			continue
+		}
+
+		if opcode != wsText {
+			return nil, &wsCloseError{WSCloseUnsupportedData, "only text messages are supported"}
+		}
+		if !utf8.Valid(message) {
+			return nil, &wsCloseError{WSCloseInvalidPayload, "invalid UTF-8 in text message"}
+		}
+		return message, nil
+	}
+}
+
+// setMaxMessageBytes lowers the message size limit to Options.MaxMessageBytes
+func (c *wsConn) setMaxMessageBytes(n int64) {
+	if n < c.maxSize {
+		c.maxSize = n
+	}
+}
+
+// WriteMessage sends msg as a single text frame
+func (c *wsConn) WriteMessage(msg []byte) error {
+	return c.writeFrame(wsText, msg)
+}
+
+// readFrame reads and unmasks a single frame
+func (c *wsConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0F
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, &wsCloseError{WSCloseProtocolError, "reserved bits set"}
+	}
+	if header[1]&0x80 == 0 {
+		return false, 0, nil, &wsCloseError{WSCloseProtocolError, "client frames must be masked"}
+	}
+
+	length := int64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		if ext[0]&0x80 != 0 {
+			return false, 0, nil, &wsCloseError{WSCloseProtocolError, "invalid payload length"}
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+	}
+
+	if opcode >= wsClose && (!fin || length > 125) {
+		return false, 0, nil, &wsCloseError{WSCloseProtocolError, "invalid control frame"}
+	}
+	if length > c.maxSize {
+		return false, 0, nil, &wsCloseError{WSCloseMessageTooBig, "frame too big"}
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.reader, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// writeFrame sends an unmasked, unfragmented frame
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	frame := make([]byte, 0, len(payload)+10)
+	frame = append(frame, 0x80|opcode)
+	switch {
+	case len(payload) <= 125:
+		frame = append(frame, byte(len(payload)))
+	case len(payload) <= 0xFFFF:
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+	frame = append(frame, payload...)
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// writeClose sends a close frame with a status code and reason.
+// Codes that must not be sent on the wire are replaced by WSCloseNormal.
+func (c *wsConn) writeClose(code int, reason string) error {
+	if !validCloseCode(code) {
+		code = WSCloseNormal
+	}
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	// The payload of a control frame is limited to 125 bytes: cut the reason on a rune boundary
+	for len(reason) > 123 || !utf8.ValidString(reason) {
+		_, size := utf8.DecodeLastRuneInString(reason)
+		reason = reason[:len(reason)-size]
+	}
+	return c.writeFrame(wsClose, append(payload, reason...))
+}
+
+// validCloseCode reports whether a close code may be sent in a close frame (RFC 6455, section 7.4).
+// 1005, 1006 and 1015 only describe a missing code or a failure; 3000-4999 are for applications.
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1014:
+		return true
+	default:
+		return code >= 3000 && code <= 4999
+	}
+}
+
+// pingLoop pings the client every interval until done is closed
+func (c *wsConn) pingLoop(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := c.writeFrame(wsPing, nil); err != nil {
+				return
+			}
+		}
+	}
+}
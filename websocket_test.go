@@ -0,0 +1,226 @@
+package go_jsonrpc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// frameRecorder is a connection whose writes are recorded
+type frameRecorder struct {
+	net.Conn
+	written bytes.Buffer
+}
+
+func (c *frameRecorder) Write(p []byte) (int, error) { return c.written.Write(p) }
+
+// clientFrame builds a frame as sent by a client, masked unless unmasked is set
+func clientFrame(fin bool, opcode byte, payload []byte, unmasked bool) []byte {
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	frame := []byte{first}
+	maskBit := byte(0x80)
+	if unmasked {
+		maskBit = 0
+	}
+	switch {
+	case len(payload) <= 125:
+		frame = append(frame, maskBit|byte(len(payload)))
+	case len(payload) <= 0xFFFF:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+	if unmasked {
+		return append(frame, payload...)
+	}
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	return frame
+}
+
+func closePayload(code int, reason string) []byte {
+	return append(binary.BigEndian.AppendUint16(nil, uint16(code)), reason...)
+}
+
+// serverFrame builds an unmasked frame as sent by the server
+func serverFrame(opcode byte, payload []byte) []byte {
+	return append([]byte{0x80 | opcode, byte(len(payload))}, payload...)
+}
+
+func TestWebSocketReadMessage(t *testing.T) {
+	frame := clientFrame
+	concat := func(frames ...[]byte) []byte { return bytes.Join(frames, nil) }
+	tests := []struct {
+		name      string
+		input     []byte
+		want      string // The message read, when no error is expected
+		wantCode  int    // Close code of the error, 0 when none
+		wantEOF   bool   // The client closed the connection
+		wantReply []byte // Frames written by the server
+	}{
+		{name: "text", input: frame(true, wsText, []byte(`{"a":1}`), false), want: `{"a":1}`},
+		{name: "extended length", input: frame(true, wsText, bytes.Repeat([]byte("a"), 300), false), want: strings.Repeat("a", 300)},
+		{name: "unmasked", input: frame(true, wsText, []byte(`{}`), true), wantCode: WSCloseProtocolError},
+		{name: "reserved bits", input: append([]byte{0xC1}, frame(true, wsText, []byte(`{}`), false)[1:]...), wantCode: WSCloseProtocolError},
+		{name: "fragmented", input: concat(frame(false, wsText, []byte(`{"a"`), false), frame(false, wsContinuation, []byte(`:`), false), frame(true, wsContinuation, []byte(`1}`), false)), want: `{"a":1}`},
+		{
+			name:      "ping between fragments",
+			input:     concat(frame(false, wsText, []byte(`{"a"`), false), frame(true, wsPing, []byte("hi"), false), frame(true, wsContinuation, []byte(`:1}`), false)),
+			want:      `{"a":1}`,
+			wantReply: serverFrame(wsPong, []byte("hi")),
+		},
+		{name: "pong ignored", input: concat(frame(true, wsPong, nil, false), frame(true, wsText, []byte(`{}`), false)), want: `{}`},
+		{name: "continuation without start", input: frame(true, wsContinuation, []byte(`{}`), false), wantCode: WSCloseProtocolError},
+		{name: "text inside fragmented message", input: concat(frame(false, wsText, []byte(`{`), false), frame(true, wsText, []byte(`}`), false)), wantCode: WSCloseProtocolError},
+		{name: "unknown opcode", input: frame(true, 0x3, []byte(`{}`), false), wantCode: WSCloseProtocolError},
+		{name: "control frame over 125 bytes", input: frame(true, wsPing, bytes.Repeat([]byte("a"), 126), false), wantCode: WSCloseProtocolError},
+		{name: "fragmented control frame", input: frame(false, wsPing, []byte("hi"), false), wantCode: WSCloseProtocolError},
+		{name: "frame too big", input: frame(true, wsText, bytes.Repeat([]byte("a"), 1025), false), wantCode: WSCloseMessageTooBig},
+		{name: "message too big", input: concat(frame(false, wsText, bytes.Repeat([]byte("a"), 600), false), frame(true, wsContinuation, bytes.Repeat([]byte("a"), 600), false)), wantCode: WSCloseMessageTooBig},
+		{name: "invalid UTF-8", input: frame(true, wsText, []byte{'"', 0xff, '"'}, false), wantCode: WSCloseInvalidPayload},
+		{name: "binary", input: frame(true, wsBinary, []byte(`{}`), false), wantCode: WSCloseUnsupportedData},
+		{name: "close without code", input: frame(true, wsClose, nil, false), wantEOF: true, wantReply: serverFrame(wsClose, closePayload(WSCloseNormal, ""))},
+		{name: "close with code", input: frame(true, wsClose, closePayload(WSCloseGoingAway, "bye"), false), wantEOF: true, wantReply: serverFrame(wsClose, closePayload(WSCloseGoingAway, ""))},
+		{name: "close with application code", input: frame(true, wsClose, closePayload(4000, ""), false), wantEOF: true, wantReply: serverFrame(wsClose, closePayload(4000, ""))},
+		{name: "close with 1-byte payload", input: frame(true, wsClose, []byte{3}, false), wantCode: WSCloseProtocolError},
+		{name: "close with 1005", input: frame(true, wsClose, closePayload(1005, ""), false), wantCode: WSCloseProtocolError},
+		{name: "close with 1006", input: frame(true, wsClose, closePayload(1006, ""), false), wantCode: WSCloseProtocolError},
+		{name: "close with 1015", input: frame(true, wsClose, closePayload(1015, ""), false), wantCode: WSCloseProtocolError},
+		{name: "close with code below range", input: frame(true, wsClose, closePayload(999, ""), false), wantCode: WSCloseProtocolError},
+		{name: "close with reserved code", input: frame(true, wsClose, closePayload(2000, ""), false), wantCode: WSCloseProtocolError},
+		{name: "close with code above range", input: frame(true, wsClose, closePayload(5000, ""), false), wantCode: WSCloseProtocolError},
+		{name: "close with invalid reason", input: frame(true, wsClose, closePayload(WSCloseNormal, "\xff"), false), wantCode: WSCloseInvalidPayload},
+		{name: "truncated frame", input: frame(true, wsText, []byte(`{"a":1}`), false)[:6], wantEOF: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &frameRecorder{}
+			ws := &wsConn{conn: conn, reader: bufio.NewReader(bytes.NewReader(tt.input)), maxSize: 1024}
+			msg, err := ws.ReadMessage()
+
+			var closeErr *wsCloseError
+			switch {
+			case tt.wantCode != 0:
+				if !errors.As(err, &closeErr) || closeErr.code != tt.wantCode {
+					t.Fatalf("got %v, want close code %d", err, tt.wantCode)
+				}
+			case tt.wantEOF:
+				if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+					t.Fatalf("got %v, want EOF", err)
+				}
+			case err != nil:
+				t.Fatalf("got %v, want %q", err, tt.want)
+			case string(msg) != tt.want:
+				t.Fatalf("got %q, want %q", msg, tt.want)
+			}
+			if !bytes.Equal(conn.written.Bytes(), tt.wantReply) {
+				t.Fatalf("server wrote %x, want %x", conn.written.Bytes(), tt.wantReply)
+			}
+		})
+	}
+}
+
+func TestWebSocketWriteClose(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     int
+		reason   string
+		wantCode int
+		wantLen  int
+	}{
+		{"normal", WSCloseNormal, "bye", WSCloseNormal, 5},
+		{"no status code", 1005, "", WSCloseNormal, 2},
+		{"abnormal", 1006, "", WSCloseNormal, 2},
+		{"out of range", 70000, "", WSCloseNormal, 2},
+		{"long reason", WSCloseInternalError, strings.Repeat("é", 100), WSCloseInternalError, 2 + 122},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &frameRecorder{}
+			ws := &wsConn{conn: conn}
+			if err := ws.writeClose(tt.code, tt.reason); err != nil {
+				t.Fatal(err)
+			}
+			frame := conn.written.Bytes()
+			payload := frame[2:]
+			if frame[0] != 0x80|wsClose || int(frame[1]) != len(payload) || len(payload) != tt.wantLen {
+				t.Fatalf("invalid close frame %x", frame)
+			}
+			if code := int(binary.BigEndian.Uint16(payload)); code != tt.wantCode {
+				t.Fatalf("got code %d, want %d", code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestWebSocketHandler(t *testing.T) {
+	r := New(quietOptions())
+	r.RegisterCommand("echo", func(ctx *Context) error {
+		return ctx.JSON(ctx.Params)
+	})
+	srv := httptest.NewServer(r.WebSocketHandler(nil))
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	handshake := "GET / HTTP/1.1\r\nHost: " + srv.Listener.Addr().String() + "\r\n" +
+		"Connection: Upgrade\r\nUpgrade: websocket\r\nSec-WebSocket-Version: 13\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n"
+	if _, err := conn.Write([]byte(handshake)); err != nil {
+		t.Fatal(err)
+	}
+	reader := bufio.NewReader(conn)
+	res, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols || res.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("handshake failed: %d %v", res.StatusCode, res.Header)
+	}
+
+	// The frames of the server are unmasked, and short enough here for a 7-bit length
+	readServerFrame := func() (byte, []byte) {
+		var header [2]byte
+		if _, err := io.ReadFull(reader, header[:]); err != nil {
+			t.Fatal(err)
+		}
+		payload := make([]byte, header[1]&0x7F)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			t.Fatal(err)
+		}
+		return header[0] & 0x0F, payload
+	}
+
+	request := `{"jsonrpc":"2.0","method":"echo","params":["hi"],"id":1}`
+	if _, err := conn.Write(clientFrame(true, wsText, []byte(request), false)); err != nil {
+		t.Fatal(err)
+	}
+	if op, payload := readServerFrame(); op != wsText || string(payload) != `{"jsonrpc":"2.0","result":["hi"],"id":1}` {
+		t.Fatalf("got opcode %d and %s", op, payload)
+	}
+
+	if _, err := conn.Write(clientFrame(true, wsClose, closePayload(WSCloseNormal, ""), false)); err != nil {
+		t.Fatal(err)
+	}
+	if op, payload := readServerFrame(); op != wsClose || int(binary.BigEndian.Uint16(payload)) != WSCloseNormal {
+		t.Fatalf("got opcode %d and %x, want a close frame", op, payload)
+	}
+}